@@ -0,0 +1,212 @@
+package athena
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/decimal128"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// arrowType returns the Arrow type of the values of col, as read from the
+// text Athena returns for them. Types with no Arrow equivalent map to
+// arrow.BinaryTypes.String.
+//
+// Athena doesn't report the types of the elements of arrays and maps, nor the
+// fields of rows, so arrays are lists of strings, maps map strings to strings
+// and rows are their text.
+func arrowType(col types.ColumnInfo) arrow.DataType {
+	switch aws.ToString(col.Type) {
+	case "tinyint":
+		return arrow.PrimitiveTypes.Int8
+	case "smallint":
+		return arrow.PrimitiveTypes.Int16
+	case "integer":
+		return arrow.PrimitiveTypes.Int32
+	case "bigint":
+		return arrow.PrimitiveTypes.Int64
+	case "boolean":
+		return arrow.FixedWidthTypes.Boolean
+	case "float", "real":
+		return arrow.PrimitiveTypes.Float32
+	case "double":
+		return arrow.PrimitiveTypes.Float64
+	case "decimal":
+		precision := col.Precision
+		if precision <= 0 || precision > decimal128.MaxPrecision {
+			precision = decimal128.MaxPrecision
+		}
+		return &arrow.Decimal128Type{Precision: precision, Scale: col.Scale}
+	case "timestamp":
+		return &arrow.TimestampType{Unit: arrow.Millisecond}
+	case "timestamp with time zone":
+		return &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}
+	case "date":
+		return arrow.FixedWidthTypes.Date32
+	case "array":
+		return arrow.ListOf(arrow.BinaryTypes.String)
+	case "map":
+		return arrow.MapOf(arrow.BinaryTypes.String, arrow.BinaryTypes.String)
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// recordSchema returns the Arrow schema of records of results with the
+// given columns. All fields are nullable, and record the Athena type of
+// their values in their metadata.
+func recordSchema(columns []types.ColumnInfo) *arrow.Schema {
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		fields[i] = arrow.Field{
+			Name:     aws.ToString(col.Name),
+			Type:     arrowType(col),
+			Nullable: true,
+			Metadata: arrow.NewMetadata([]string{"athena.type"}, []string{aws.ToString(col.Type)}),
+		}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// recordBuilder accumulates rows into Arrow records.
+type recordBuilder struct {
+	b    *array.RecordBuilder
+	rows int
+}
+
+func newRecordBuilder(mem memory.Allocator, schema *arrow.Schema) *recordBuilder {
+	return &recordBuilder{b: array.NewRecordBuilder(mem, schema)}
+}
+
+// appendRow adds a row of results, as returned by Athena, to the record being
+// built.
+func (b *recordBuilder) appendRow(row types.Row) error {
+	for i, datum := range row.Data {
+		if err := appendArrowText(b.b.Field(i), datum.VarCharValue); err != nil {
+			return fmt.Errorf("athena: column %q: %w", b.b.Schema().Field(i).Name, err)
+		}
+	}
+	b.rows++
+	return nil
+}
+
+// newRecord returns a record of the rows appended since the last call. The
+// caller must release it.
+func (b *recordBuilder) newRecord() arrow.Record {
+	b.rows = 0
+	return b.b.NewRecord()
+}
+
+func (b *recordBuilder) release() {
+	b.b.Release()
+}
+
+// appendArrowText appends a value from the text Athena returns for it.
+func appendArrowText(b array.Builder, text *string) error {
+	if text == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	s := *text
+	switch b := b.(type) {
+	case *array.Int8Builder:
+		n, err := strconv.ParseInt(s, 10, 8)
+		if err != nil {
+			return err
+		}
+		b.Append(int8(n))
+	case *array.Int16Builder:
+		n, err := strconv.ParseInt(s, 10, 16)
+		if err != nil {
+			return err
+		}
+		b.Append(int16(n))
+	case *array.Int32Builder:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		b.Append(int32(n))
+	case *array.Int64Builder:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		b.Append(n)
+	case *array.BooleanBuilder:
+		t, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		b.Append(t)
+	case *array.Float32Builder:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return err
+		}
+		b.Append(float32(f))
+	case *array.Float64Builder:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		b.Append(f)
+	case *array.Decimal128Builder:
+		typ := b.Type().(*arrow.Decimal128Type)
+		n, err := decimal128.FromString(s, typ.Precision, typ.Scale)
+		if err != nil {
+			return err
+		}
+		b.Append(n)
+	case *array.TimestampBuilder:
+		layout := TimestampLayout
+		if b.Type().(*arrow.TimestampType).TimeZone != "" {
+			layout = TimestampWithTimeZoneLayout
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Timestamp(t.UnixMilli()))
+	case *array.Date32Builder:
+		t, err := time.Parse(DateLayout, s)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Date32FromTime(t))
+	case *array.StringBuilder:
+		b.Append(s)
+	case *array.ListBuilder:
+		elems, err := parseArray(s)
+		if err != nil {
+			return err
+		}
+		b.Append(true)
+		for _, elem := range elems {
+			if err := appendArrowText(b.ValueBuilder(), elem); err != nil {
+				return err
+			}
+		}
+	case *array.MapBuilder:
+		entries, err := parseMap(s)
+		if err != nil {
+			return err
+		}
+		b.Append(true)
+		for _, entry := range entries {
+			b.KeyBuilder().(*array.StringBuilder).Append(entry.key)
+			if err := appendArrowText(b.ItemBuilder(), entry.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported Arrow type %s", b.Type())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package athena
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// ErrScanBudgetExceeded is matched by errors.Is for queries rejected or stopped
+// because they scanned, or were estimated to scan, more than allowed by
+// MaxBytesScanned. Use errors.As with *ScanBudgetError for the details.
+var ErrScanBudgetExceeded = errors.New("athena: scan budget exceeded")
+
+// ScanBudgetError is returned for queries exceeding their MaxBytesScanned.
+type ScanBudgetError struct {
+	QueryID QueryID
+	Scanned int64
+	Limit   int64
+
+	// Estimated is true if the query was rejected before it ran, because the
+	// estimate of its EXPLAIN plan exceeded the limit. QueryID is empty then.
+	Estimated bool
+}
+
+func (e *ScanBudgetError) Error() string {
+	if e.Estimated {
+		return fmt.Sprintf("athena: query is estimated to scan %d bytes, over the budget of %d bytes", e.Scanned, e.Limit)
+	}
+	return fmt.Sprintf("athena: query %s stopped after scanning %d bytes, over the budget of %d bytes", e.QueryID, e.Scanned, e.Limit)
+}
+
+func (e *ScanBudgetError) Is(target error) bool {
+	return target == ErrScanBudgetExceeded
+}
+
+// scanBudgetCheck returns a pollQuery check failing once a query scanned more
+// than limit bytes. A non-positive limit disables the check.
+func scanBudgetCheck(limit int64) func(*types.QueryExecution) error {
+	return func(qe *types.QueryExecution) error {
+		if limit <= 0 {
+			return nil
+		}
+
+		scanned := dataScannedInBytes(qe)
+		if scanned <= limit {
+			return nil
+		}
+
+		return &ScanBudgetError{
+			QueryID: QueryID(aws.ToString(qe.QueryExecutionId)),
+			Scanned: scanned,
+			Limit:   limit,
+		}
+	}
+}
+
+// dataScannedInBytes returns the data scanned so far by a query execution.
+func dataScannedInBytes(qe *types.QueryExecution) int64 {
+	if qe.Statistics == nil {
+		return 0
+	}
+	return aws.ToInt64(qe.Statistics.DataScannedInBytes)
+}
+
+// checkScanEstimate runs `EXPLAIN (TYPE IO)` for query and rejects it if
+// Athena estimates it'll scan more than opts.MaxBytesScanned. Queries that
+// can't be explained, or whose estimate is unknown, are let through.
+//
+// The EXPLAIN is stopped by a shutdown like other queries, and its results
+// are cleaned up if the driver is configured to. It's part of running the
+// query, so it's not reported to the hooks, logs or progress callbacks.
+func (c *conn) checkScanEstimate(ctx context.Context, query string, opts QueryOptions) error {
+	if opts.MaxBytesScanned <= 0 || !isReadQuery(query) {
+		return nil
+	}
+	if c.queries.isClosed() {
+		return ErrShutdown
+	}
+
+	c = c.silent()
+	ctx = WithProgress(ctx, nil)
+
+	// The token identifies the query itself, not its EXPLAIN.
+	opts.ClientRequestToken = ""
+	queryID, err := c.startQuery(ctx, "EXPLAIN (TYPE IO, FORMAT JSON) "+query, opts)
+	if err != nil {
+		return nil
+	}
+	if !c.queries.add(queryID, c) {
+		c.stopQuery(context.WithoutCancel(ctx), queryID, ErrShutdown)
+		return ErrShutdown
+	}
+
+	qe, err := c.waitOnQuery(ctx, queryID, opts)
+	if !c.queries.remove(queryID) {
+		return ErrShutdown
+	}
+	if qe != nil && qe.ResultConfiguration != nil {
+		defer c.cleaner.add(aws.ToString(qe.ResultConfiguration.OutputLocation))
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	plan, err := c.readPlan(ctx, queryID)
+	if err != nil {
+		return nil
+	}
+
+	estimate, ok := estimatedInputBytes(plan)
+	if !ok || estimate <= opts.MaxBytesScanned {
+		return nil
+	}
+
+	return &ScanBudgetError{
+		Scanned:   estimate,
+		Limit:     opts.MaxBytesScanned,
+		Estimated: true,
+	}
+}
+
+// readPlan returns the text of an EXPLAIN query's results.
+func (c *conn) readPlan(ctx context.Context, queryID string) (string, error) {
+	r, err := newRows(ctx, rowsConfig{Athena: c.athena, QueryID: queryID})
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var plan strings.Builder
+	dest := make([]driver.Value, len(r.Columns()))
+	for {
+		if err := r.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		if line, ok := dest[0].(string); ok {
+			plan.WriteString(line)
+			plan.WriteByte('\n')
+		}
+	}
+
+	return plan.String(), nil
+}
+
+// estimatedInputBytes sums the estimated size of every input of an
+// `EXPLAIN (TYPE IO, FORMAT JSON)` plan. It reports false if any of them is
+// unknown.
+func estimatedInputBytes(plan string) (int64, bool) {
+	// The results may start with a header row.
+	start := strings.IndexByte(plan, '{')
+	if start < 0 {
+		return 0, false
+	}
+
+	var explain struct {
+		InputTableColumnInfos []struct {
+			Estimate struct {
+				OutputSizeInBytes json.RawMessage `json:"outputSizeInBytes"`
+			} `json:"estimate"`
+		} `json:"inputTableColumnInfos"`
+	}
+	if err := json.NewDecoder(strings.NewReader(plan[start:])).Decode(&explain); err != nil {
+		return 0, false
+	}
+
+	var total float64
+	for _, input := range explain.InputTableColumnInfos {
+		// Unknown estimates are serialized as "NaN".
+		raw := strings.Trim(string(input.Estimate.OutputSizeInBytes), `"`)
+		size, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(size) || math.IsInf(size, 0) {
+			return 0, false
+		}
+		total += size
+	}
+
+	return int64(total), len(explain.InputTableColumnInfos) > 0
+}
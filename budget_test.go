@@ -0,0 +1,141 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_MaxBytesScanned(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	db := openTestDB(t, fake)
+
+	ctx := WithQueryOptions(context.Background(), QueryOptions{MaxBytesScanned: 2000})
+	_, err := db.ExecContext(ctx, "select")
+	require.ErrorIs(t, err, ErrScanBudgetExceeded)
+
+	var budgetErr *ScanBudgetError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, QueryID("query-1"), budgetErr.QueryID)
+	assert.Equal(t, int64(2048), budgetErr.Scanned)
+	assert.Equal(t, int64(2000), budgetErr.Limit)
+	assert.Equal(t, []string{"query-1"}, fake.stopped)
+}
+
+// explainResponse returns the results of an `EXPLAIN (TYPE IO, FORMAT JSON)`
+// estimating the query scans size bytes.
+func explainResponse(size string) genQueryResultsOutputByToken {
+	return func(string) (*athena.GetQueryResultsOutput, error) {
+		columns := []types.ColumnInfo{genColumnInfo("Query Plan")}
+		plan := `{"inputTableColumnInfos": [{"estimate": {"outputSizeInBytes": ` + size + `}}]}`
+		return &athena.GetQueryResultsOutput{
+			ResultSet: &types.ResultSet{
+				ResultSetMetadata: &types.ResultSetMetadata{ColumnInfo: columns},
+				Rows: []types.Row{
+					{Data: []types.Datum{{VarCharValue: aws.String("Query Plan")}}},
+					{Data: []types.Datum{{VarCharValue: aws.String(plan)}}},
+				},
+			},
+		}, nil
+	}
+}
+
+func init() {
+	queryToResultsGenMap["EXPLAIN (TYPE IO, FORMAT JSON) select"] = explainResponse("5000.0")
+	queryToResultsGenMap["EXPLAIN (TYPE IO, FORMAT JSON) select *"] = explainResponse(`"NaN"`)
+	queryToResultsGenMap["select *"] = dummySelectQueryResponse
+}
+
+func TestConn_CheckScanEstimates(t *testing.T) {
+	fake := newFakeAthena()
+	connector, api := newCleanupConnector(t, fake, DriverConfig{
+		CheckScanEstimates: true,
+		CleanupResults:     true,
+		MaxBytesScanned:    2000,
+	})
+	db := openTestConnector(t, connector)
+
+	_, err := db.QueryContext(context.Background(), "select")
+	var budgetErr *ScanBudgetError
+	require.True(t, errors.As(err, &budgetErr), "%v", err)
+	assert.True(t, budgetErr.Estimated)
+	assert.Equal(t, int64(5000), budgetErr.Scanned)
+	assert.Equal(t, int64(2000), budgetErr.Limit)
+
+	// Only the EXPLAIN ran, and its results were cleaned up.
+	require.Len(t, fake.started, 1)
+	assert.Equal(t, "EXPLAIN (TYPE IO, FORMAT JSON) select", aws.ToString(fake.started[0].QueryString))
+	assert.Zero(t, inFlight(connector))
+	require.NoError(t, connector.cleaner.flush(context.Background()))
+	assert.Equal(t, [][]string{{
+		"test-bucket/output/query-1.csv",
+		"test-bucket/output/query-1.csv.metadata",
+	}}, api.deletes)
+
+	// Queries whose estimate is unknown run.
+	assert.Len(t, readAll(t, db, context.Background(), "select *"), 9)
+	require.Len(t, fake.started, 3)
+	assert.Equal(t, "select *", aws.ToString(fake.started[2].QueryString))
+}
+
+func TestConn_CheckScanEstimatesShutdown(t *testing.T) {
+	connector := newTestConnector(t, newFakeAthena(), DriverConfig{
+		CheckScanEstimates: true,
+		MaxBytesScanned:    2000,
+	})
+	db := openTestConnector(t, connector)
+	require.NoError(t, connector.Shutdown(context.Background()))
+
+	_, err := db.QueryContext(context.Background(), "select")
+	assert.ErrorIs(t, err, ErrShutdown)
+}
+
+func TestEstimatedInputBytes(t *testing.T) {
+	tests := []struct {
+		desc     string
+		plan     string
+		expected int64
+		ok       bool
+	}{
+		{
+			desc: "header and two inputs",
+			plan: `Query Plan
+{
+  "inputTableColumnInfos" : [ {
+    "table" : { "catalog" : "awsdatacatalog", "schemaTable" : { "schema" : "db", "table" : "a" } },
+    "estimate" : { "outputRowCount" : 10.0, "outputSizeInBytes" : 1500.0 }
+  }, {
+    "table" : { "catalog" : "awsdatacatalog", "schemaTable" : { "schema" : "db", "table" : "b" } },
+    "estimate" : { "outputRowCount" : 10.0, "outputSizeInBytes" : 500.0 }
+  } ]
+}
+`,
+			expected: 2000,
+			ok:       true,
+		},
+		{
+			desc: "unknown estimate",
+			plan: `{"inputTableColumnInfos": [{"estimate": {"outputSizeInBytes": "NaN"}}]}`,
+		},
+		{
+			desc: "no inputs",
+			plan: `{"inputTableColumnInfos": []}`,
+		},
+		{
+			desc: "not json",
+			plan: "Fragment 0 [SINGLE]",
+		},
+	}
+
+	for _, test := range tests {
+		estimate, ok := estimatedInputBytes(test.plan)
+		assert.Equal(t, test.ok, ok, test.desc)
+		assert.Equal(t, test.expected, estimate, test.desc)
+	}
+}
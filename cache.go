@@ -0,0 +1,376 @@
+package athena
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ResultCache stores the results of queries, so that rerunning a query
+// returns them rather than running it in Athena again. Set it on
+// DriverConfig.ResultCache. NewMemoryCache and NewDiskCache return
+// implementations keeping results in memory and on disk.
+//
+// Only the results of SELECT queries read to the end are cached. Keys are
+// derived from the normalized SQL and the options the query ran with.
+//
+// Results read from the cache don't run a query, so they aren't reported to
+// the Hooks, nor counted by the metrics built on them. Their ExecutionInfo
+// is that of the query that produced them, marked Cached.
+type ResultCache interface {
+	// Get returns the result stored for key, if any and not expired.
+	Get(key string) (*CachedResult, bool)
+
+	// Put stores result for key. Implementations may drop it, e.g. if it's
+	// too large.
+	Put(key string, result *CachedResult)
+}
+
+// CachedResult is a query's results as stored in a ResultCache.
+type CachedResult struct {
+	Columns []CachedColumn
+
+	// Rows are the values of the rows, as returned by Athena. Nil values
+	// are NULLs.
+	Rows [][]*string
+
+	// Info describes the execution that produced the results.
+	Info     ExecutionInfo
+	CachedAt time.Time
+}
+
+// CachedColumn is a column of a CachedResult.
+type CachedColumn struct {
+	Name string
+	Type string
+
+	// Precision and Scale are those of decimal columns.
+	Precision int32
+	Scale     int32
+}
+
+// size approximates the memory used by r.
+func (r *CachedResult) size() int64 {
+	size := int64(len(r.Info.Query)) + 512
+	for _, col := range r.Columns {
+		size += int64(len(col.Name) + len(col.Type))
+	}
+	for _, row := range r.Rows {
+		size += 24
+		for _, val := range row {
+			size += 8
+			if val != nil {
+				size += int64(len(*val))
+			}
+		}
+	}
+	return size
+}
+
+// MemoryCache is a ResultCache keeping results in memory, evicting the least
+// recently used ones to stay under its size limit.
+type MemoryCache struct {
+	ttl      time.Duration
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     list.List // of *memoryCacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key    string
+	result *CachedResult
+	size   int64
+}
+
+// NewMemoryCache returns a MemoryCache keeping results up to ttl, and up to
+// maxBytes of them in total.
+func NewMemoryCache(ttl time.Duration, maxBytes int64) *MemoryCache {
+	return &MemoryCache{ttl: ttl, maxBytes: maxBytes, entries: map[string]*list.Element{}}
+}
+
+// Get implements ResultCache.
+func (c *MemoryCache) Get(key string) (*CachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryCacheEntry)
+	if time.Since(entry.result.CachedAt) > c.ttl {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.result, true
+}
+
+// Put implements ResultCache.
+func (c *MemoryCache) Put(key string, result *CachedResult) {
+	size := result.size()
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&memoryCacheEntry{key: key, result: result, size: size})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *MemoryCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*memoryCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+var _ ResultCache = (*MemoryCache)(nil)
+
+// isReadQuery reports whether query is a statement reading data, whose
+// results may be cached or reused and whose scan may be estimated with
+// EXPLAIN.
+func isReadQuery(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch strings.ToUpper(strings.TrimLeft(fields[0], "(")) {
+	case "SELECT", "WITH", "VALUES", "TABLE":
+		return true
+	default:
+		return false
+	}
+}
+
+// cacheKey identifies the results of a query run with opts.
+func cacheKey(query string, opts QueryOptions) string {
+	h := sha256.New()
+	for _, field := range []string{
+		normalizeQuery(query),
+		opts.Catalog,
+		opts.Database,
+		opts.WorkGroup,
+		strconv.Itoa(int(opts.ResultMode)),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeQuery collapses the whitespace of query outside of quotes and
+// comments, and drops any trailing semicolon, so that formatting doesn't
+// defeat caching. Quotes and comments are kept as is, as well as the line
+// breaks ending comments, which would otherwise comment out the next line.
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	// closing ends the quote or comment being copied, if any.
+	closing := ""
+	space := false
+	for i := 0; i < len(query); i++ {
+		if closing != "" {
+			if strings.HasPrefix(query[i:], closing) {
+				b.WriteString(closing)
+				i += len(closing) - 1
+				closing = ""
+			} else {
+				b.WriteByte(query[i])
+			}
+			continue
+		}
+
+		ch, width := query[i], 1
+		switch {
+		case ch == '\'' || ch == '"':
+			closing = string(ch)
+		case strings.HasPrefix(query[i:], "--"):
+			closing, width = "\n", 2
+		case strings.HasPrefix(query[i:], "/*"):
+			closing, width = "*/", 2
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(query[i : i+width])
+		i += width - 1
+	}
+	return strings.TrimRight(strings.TrimSuffix(b.String(), ";"), " \n")
+}
+
+// maxCachedResultBytes bounds the size of the results collected to be
+// cached, so that reading large results doesn't buffer them all in memory.
+const maxCachedResultBytes = 64 << 20
+
+// cacheWriter collects the rows read from a query's results, to store them
+// in the cache once they're all read.
+type cacheWriter struct {
+	cache  ResultCache
+	key    string
+	result CachedResult
+}
+
+func newCacheWriter(cache ResultCache, key string, info *ExecutionInfo) *cacheWriter {
+	w := &cacheWriter{cache: cache, key: key}
+	w.result.Info = *info
+	w.result.Info.RuntimeStatistics = nil
+	return w
+}
+
+// page collects the rows of a page of results. It returns false once the
+// results grew too large to be cached.
+func (w *cacheWriter) page(columns []types.ColumnInfo, rows []types.Row) bool {
+	if w.result.Columns == nil {
+		w.result.Columns = []CachedColumn{}
+		for _, col := range columns {
+			w.result.Columns = append(w.result.Columns, CachedColumn{
+				Name:      *col.Name,
+				Type:      *col.Type,
+				Precision: col.Precision,
+				Scale:     col.Scale,
+			})
+		}
+	}
+
+	for _, row := range rows {
+		values := make([]*string, len(row.Data))
+		for i, datum := range row.Data {
+			values[i] = datum.VarCharValue
+		}
+		w.result.Rows = append(w.result.Rows, values)
+	}
+	return w.result.size() <= maxCachedResultBytes
+}
+
+// done stores the rows collected once the results were read to the end.
+func (w *cacheWriter) done() {
+	w.result.CachedAt = time.Now()
+	w.cache.Put(w.key, &w.result)
+}
+
+// cachedRows are driver.Rows reading a CachedResult.
+type cachedRows struct {
+	columns []types.ColumnInfo
+	rows    [][]*string
+}
+
+func newCachedRows(result *CachedResult) *cachedRows {
+	r := &cachedRows{rows: result.Rows}
+	for _, col := range result.Columns {
+		name, typ := col.Name, col.Type
+		r.columns = append(r.columns, types.ColumnInfo{
+			Name:      &name,
+			Type:      &typ,
+			Precision: col.Precision,
+			Scale:     col.Scale,
+		})
+	}
+	return r
+}
+
+func (r *cachedRows) Columns() []string {
+	var columns []string
+	for _, col := range r.columns {
+		columns = append(columns, *col.Name)
+	}
+	return columns
+}
+
+func (r *cachedRows) ColumnTypeDatabaseTypeName(index int) string {
+	return *r.columns[index].Type
+}
+
+func (r *cachedRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	data := make([]types.Datum, len(r.rows[0]))
+	for i, val := range r.rows[0] {
+		data[i].VarCharValue = val
+	}
+	if err := convertRow(r.columns, data, dest); err != nil {
+		return err
+	}
+
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *cachedRows) columnInfo() []types.ColumnInfo {
+	return r.columns
+}
+
+// nextPage returns the rows that weren't read yet, all at once.
+func (r *cachedRows) nextPage() ([]types.Row, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+
+	page := make([]types.Row, len(r.rows))
+	for i, values := range r.rows {
+		page[i].Data = make([]types.Datum, len(values))
+		for j, val := range values {
+			page[i].Data[j].VarCharValue = val
+		}
+	}
+	r.rows = nil
+	return page, nil
+}
+
+func (r *cachedRows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+// resultCacheKey returns the key of the results of query in the cache, if
+// they may be cached.
+func (c *conn) resultCacheKey(query string, opts QueryOptions) (string, bool) {
+	if c.cache == nil || !isReadQuery(query) {
+		return "", false
+	}
+	return cacheKey(query, opts), true
+}
+
+// cachedRows returns rows reading the cached result of a query.
+func (c *conn) cachedRows(ctx context.Context, result *CachedResult) *cachedRows {
+	info := result.Info
+	info.Cached = true
+	recordExecutionInfo(ctx, &info)
+
+	trace.SpanFromContext(ctx).SetAttributes(attrQueryID.String(string(info.QueryID)), attrCached.Bool(true))
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "athena: results read from cache",
+		slog.String("query_id", string(info.QueryID)),
+		slog.Time("cached_at", result.CachedAt),
+	)
+	return newCachedRows(result)
+}
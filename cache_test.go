@@ -0,0 +1,227 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	queryToResultsGenMap["select empty"] = dummyEmptyResponse
+}
+
+// dummyEmptyResponse returns results with two columns and no rows.
+func dummyEmptyResponse(_ string) (*athena.GetQueryResultsOutput, error) {
+	columns := []types.ColumnInfo{genColumnInfo("first_name"), genColumnInfo("last_name")}
+	return &athena.GetQueryResultsOutput{
+		ResultSet: &types.ResultSet{
+			ResultSetMetadata: &types.ResultSetMetadata{ColumnInfo: columns},
+			Rows:              []types.Row{genRow(true, columns)},
+		},
+	}, nil
+}
+
+// readAll returns the values of every row of query's results.
+func readAll(t *testing.T, db *sql.DB, ctx context.Context, query string) [][]string {
+	rows, err := db.QueryContext(ctx, query)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+
+	var all [][]string
+	for rows.Next() {
+		values := make([]string, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		require.NoError(t, rows.Scan(dest...))
+		all = append(all, values)
+	}
+	require.NoError(t, rows.Err())
+	return all
+}
+
+func TestConn_ResultCache(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		ResultCache: NewMemoryCache(time.Hour, 1<<20),
+	}))
+
+	var info ExecutionInfo
+	ctx := WithExecutionInfo(context.Background(), &info)
+	expected := readAll(t, db, ctx, "select")
+	require.Len(t, expected, 9)
+	assert.False(t, info.Cached)
+
+	info = ExecutionInfo{}
+	assert.Equal(t, expected, readAll(t, db, ctx, "  select ;"))
+	assert.True(t, info.Cached)
+	assert.Equal(t, QueryID("query-1"), info.QueryID)
+	assert.Len(t, fake.started, 1)
+
+	// Other options, other results.
+	readAll(t, db, WithQueryOptions(ctx, QueryOptions{Database: "other"}), "select")
+	assert.Len(t, fake.started, 2)
+
+	readAll(t, db, WithQueryOptions(ctx, QueryOptions{NoCache: true}), "select")
+	assert.False(t, info.Cached)
+	assert.Len(t, fake.started, 3)
+
+	// Only the results of SELECT queries are cached.
+	readAll(t, db, ctx, "show")
+	readAll(t, db, ctx, "show")
+	assert.Len(t, fake.started, 5)
+}
+
+func TestConn_ResultCachePartialRead(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		ResultCache: NewMemoryCache(time.Hour, 1<<20),
+	}))
+
+	rows, err := db.QueryContext(context.Background(), "select")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Close())
+
+	readAll(t, db, context.Background(), "select")
+	assert.Len(t, fake.started, 2)
+}
+
+func TestConn_ResultCacheEmpty(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		ResultCache: NewMemoryCache(time.Hour, 1<<20),
+	}))
+
+	for i := 0; i < 2; i++ {
+		rows, err := db.QueryContext(context.Background(), "select empty")
+		require.NoError(t, err)
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first_name", "last_name"}, columns)
+		assert.False(t, rows.Next())
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+	}
+	assert.Len(t, fake.started, 1)
+}
+
+func TestConn_ResultCacheShutdown(t *testing.T) {
+	connector := newTestConnector(t, newFakeAthena(), DriverConfig{
+		ResultCache: NewMemoryCache(time.Hour, 1<<20),
+	})
+	db := openTestConnector(t, connector)
+	readAll(t, db, context.Background(), "select")
+
+	require.NoError(t, connector.Shutdown(context.Background()))
+	_, err := db.QueryContext(context.Background(), "select")
+	assert.ErrorIs(t, err, ErrShutdown)
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	assert.Equal(t, "SELECT a, b FROM t WHERE c = '  x  '",
+		normalizeQuery("\n  SELECT a,\n\t b  FROM t\nWHERE c = '  x  ';"))
+	assert.Equal(t, `SELECT "a  b" FROM t`, normalizeQuery(`SELECT  "a  b"  FROM t`))
+
+	// Comments are kept, with the line breaks ending them.
+	assert.Equal(t, "SELECT 1 -- note\n, 2", normalizeQuery("SELECT 1  -- note\n   ,  2"))
+	assert.Equal(t, "SELECT 1 -- note , 2", normalizeQuery("SELECT 1 -- note , 2"))
+	assert.Equal(t, "SELECT /* a  b */ 1", normalizeQuery("SELECT  /* a  b */  1"))
+	assert.Equal(t, "SELECT /*/ a */ 1", normalizeQuery("SELECT /*/ a */ 1"))
+	assert.Equal(t, "SELECT 1 -- note", normalizeQuery("SELECT 1 -- note\n;"))
+	assert.NotEqual(t,
+		cacheKey("SELECT 1 -- note\n, 2", QueryOptions{}),
+		cacheKey("SELECT 1 -- note , 2", QueryOptions{}))
+}
+
+func cachedResult(rows int) *CachedResult {
+	result := &CachedResult{
+		Columns:  []CachedColumn{{Name: "a", Type: "varchar"}},
+		CachedAt: time.Now(),
+	}
+	for i := 0; i < rows; i++ {
+		result.Rows = append(result.Rows, []*string{aws.String(fmt.Sprint(i))})
+	}
+	return result
+}
+
+func testResultCache(t *testing.T, cache ResultCache, expire func(key string)) {
+	cache.Put("a", cachedResult(1))
+	result, ok := cache.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, []*string{aws.String("0")}, result.Rows[0])
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	expire("a")
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+
+	// Results larger than the cache aren't stored.
+	cache.Put("huge", cachedResult(100000))
+	_, ok = cache.Get("huge")
+	assert.False(t, ok)
+}
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(time.Minute, 2*cachedResult(10).size())
+	testResultCache(t, cache, func(key string) {
+		result, _ := cache.Get(key)
+		result.CachedAt = time.Now().Add(-time.Hour)
+	})
+
+	cache.Put("a", cachedResult(10))
+	cache.Put("b", cachedResult(10))
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+
+	// b is the least recently used.
+	cache.Put("c", cachedResult(10))
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+}
+
+func TestDiskCache(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir(), time.Minute, 100<<10)
+	require.NoError(t, err)
+	testResultCache(t, cache, func(key string) {
+		result, _ := cache.Get(key)
+		result.CachedAt = time.Now().Add(-time.Hour)
+		cache.Put(key, result)
+	})
+
+	// Entries survive the cache.
+	cache.Put("a", cachedResult(10))
+	other, err := NewDiskCache(cache.dir, time.Minute, 1<<20)
+	require.NoError(t, err)
+	result, ok := other.Get("a")
+	require.True(t, ok)
+	assert.Len(t, result.Rows, 10)
+}
+
+func TestDiskCache_Eviction(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir(), time.Minute, 1000)
+	require.NoError(t, err)
+
+	cache.Put("a", cachedResult(10))
+	cache.Put("b", cachedResult(10))
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	_, ok = cache.Get("b")
+	assert.True(t, ok)
+}
@@ -0,0 +1,239 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CleanupHooks may be implemented by Hooks to be notified of the query
+// result objects the driver failed to delete. See DriverConfig.CleanupResults.
+type CleanupHooks interface {
+	// OnCleanupError is called with the S3 URLs of the objects that couldn't
+	// be deleted, and why.
+	OnCleanupError(objects []string, err error)
+}
+
+const (
+	// maxDeleteObjects is the most objects a DeleteObjects call deletes.
+	maxDeleteObjects = 1000
+
+	// cleanupDelay is how long result objects wait to be deleted, so that
+	// they're deleted in batches.
+	cleanupDelay = 5 * time.Second
+)
+
+// resultCleaner deletes the result objects of queries in batches. A nil
+// *resultCleaner deletes nothing.
+type resultCleaner struct {
+	s3     S3API
+	hooks  *hookDispatcher
+	logger *slog.Logger
+	delay  time.Duration
+
+	mu       sync.Mutex
+	pending  map[string][]string // keys by bucket
+	timer    *time.Timer
+	deleting sync.WaitGroup
+}
+
+func newResultCleaner(api S3API, hooks *hookDispatcher, logger *slog.Logger) *resultCleaner {
+	return &resultCleaner{
+		s3:      api,
+		hooks:   hooks,
+		logger:  logger,
+		delay:   cleanupDelay,
+		pending: map[string][]string{},
+	}
+}
+
+// add queues the deletion of the results of a query at outputLocation, and
+// of their metadata file.
+func (c *resultCleaner) add(outputLocation string) {
+	if c == nil {
+		return
+	}
+
+	bucket, key, err := parseS3URL(outputLocation)
+	if err != nil {
+		c.logger.LogAttrs(context.Background(), slog.LevelWarn, "athena: not deleting query results",
+			slog.String("output_location", outputLocation),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := append(c.pending[bucket], key, key+".metadata")
+	if len(keys) >= maxDeleteObjects {
+		delete(c.pending, bucket)
+		c.deleting.Add(1)
+		go func() {
+			defer c.deleting.Done()
+			c.delete(context.Background(), bucket, keys)
+		}()
+		return
+	}
+
+	c.pending[bucket] = keys
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.delay, func() { c.flush(context.Background()) })
+	}
+}
+
+// flush deletes the queued objects, returning once they're deleted or ctx is
+// done.
+func (c *resultCleaner) flush(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[string][]string{}
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for bucket, keys := range pending {
+		errs = append(errs, c.delete(ctx, bucket, keys))
+	}
+
+	deleted := make(chan struct{})
+	go func() {
+		c.deleting.Wait()
+		close(deleted)
+	}()
+	select {
+	case <-deleted:
+	case <-ctx.Done():
+		errs = append(errs, ctx.Err())
+	}
+	return errors.Join(errs...)
+}
+
+// delete deletes keys from bucket, reporting failures to the hooks.
+func (c *resultCleaner) delete(ctx context.Context, bucket string, keys []string) error {
+	var errs []error
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), maxDeleteObjects)]
+		keys = keys[len(batch):]
+
+		failed, err := deleteObjects(ctx, c.s3, bucket, batch)
+		if err == nil {
+			continue
+		}
+
+		urls := make([]string, len(failed))
+		for i, key := range failed {
+			urls[i] = "s3://" + bucket + "/" + key
+		}
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "athena: deleting query results failed",
+			slog.String("bucket", bucket),
+			slog.Int("objects", len(failed)),
+			slog.Any("error", err),
+		)
+		c.hooks.onCleanupError(urls, err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// deleteObjects deletes up to maxDeleteObjects keys from bucket, returning
+// the keys it failed to delete. Deleting missing objects isn't an error.
+func deleteObjects(ctx context.Context, api S3API, bucket string, keys []string) (failed []string, err error) {
+	objects := make([]s3types.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		objects[i] = s3types.ObjectIdentifier{Key: aws.String(key)}
+	}
+
+	out, err := api.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		return keys, err
+	}
+
+	var errs []error
+	for _, objErr := range out.Errors {
+		failed = append(failed, aws.ToString(objErr.Key))
+		errs = append(errs, fmt.Errorf("%s: %s", aws.ToString(objErr.Key), aws.ToString(objErr.Message)))
+	}
+	return failed, errors.Join(errs...)
+}
+
+// parseS3URL splits an S3 URL into its bucket and key.
+func parseS3URL(s3URL string) (bucket, key string, err error) {
+	u, err := url.Parse(s3URL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("not an S3 URL: %q", s3URL)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// PurgeResults deletes the objects under prefix, an S3 URL such as the
+// OutputLocation of a workgroup, last modified more than olderThan ago. It's
+// meant to be run periodically, to delete the results of queries not cleaned
+// up with DriverConfig.CleanupResults. It returns the number of objects
+// deleted.
+func PurgeResults(ctx context.Context, client S3API, prefix string, olderThan time.Duration) (int, error) {
+	bucket, keyPrefix, err := parseS3URL(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	before := time.Now().Add(-olderThan)
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(keyPrefix),
+	})
+
+	deleted := 0
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return deleted, err
+		}
+
+		var keys []string
+		for _, obj := range page.Contents {
+			if aws.ToTime(obj.LastModified).Before(before) {
+				keys = append(keys, aws.ToString(obj.Key))
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		failed, err := deleteObjects(ctx, client, bucket, keys)
+		deleted += len(keys) - len(failed)
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// PurgeResults is like the PurgeResults function, using the S3 client of the
+// connector.
+func (c *Connector) PurgeResults(ctx context.Context, prefix string, olderThan time.Duration) (int, error) {
+	return PurgeResults(ctx, c.cleaner.s3, prefix, olderThan)
+}
@@ -0,0 +1,168 @@
+package athena
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-memory S3API.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects []s3types.Object
+	deletes [][]string
+	failed  map[string]bool
+}
+
+func (f *fakeS3) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, opts ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var keys []string
+	out := &s3.DeleteObjectsOutput{}
+	for _, obj := range in.Delete.Objects {
+		key := aws.ToString(obj.Key)
+		if f.failed[key] {
+			out.Errors = append(out.Errors, s3types.Error{Key: obj.Key, Message: aws.String("access denied")})
+			continue
+		}
+		keys = append(keys, aws.ToString(in.Bucket)+"/"+key)
+	}
+	sort.Strings(keys)
+	f.deletes = append(f.deletes, keys)
+	return out, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return &s3.ListObjectsV2Output{Contents: f.objects}, nil
+}
+
+// cleanupErrors records the failures reported to CleanupHooks.
+type cleanupErrors struct {
+	NopHooks
+	objects []string
+}
+
+func (h *cleanupErrors) OnCleanupError(objects []string, err error) {
+	h.objects = append(h.objects, objects...)
+}
+
+func newCleanupConnector(t *testing.T, fake *fakeAthena, cfg DriverConfig) (*Connector, *fakeS3) {
+	connector := newTestConnector(t, fake, cfg)
+	api := &fakeS3{}
+	connector.cleaner.s3 = api
+	connector.cleaner.delay = time.Hour
+	return connector, api
+}
+
+func TestConn_CleanupResults(t *testing.T) {
+	connector, api := newCleanupConnector(t, newFakeAthena(), DriverConfig{CleanupResults: true})
+	db := openTestConnector(t, connector)
+
+	rows, err := db.QueryContext(context.Background(), "select")
+	require.NoError(t, err)
+	require.NoError(t, connector.cleaner.flush(context.Background()))
+	assert.Empty(t, api.deletes, "results must be kept until the rows are closed")
+	require.NoError(t, rows.Close())
+
+	_, err = db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+
+	// Both queries are cleaned up in a single batch.
+	require.NoError(t, connector.cleaner.flush(context.Background()))
+	assert.Equal(t, [][]string{{
+		"test-bucket/output/query-1.csv",
+		"test-bucket/output/query-1.csv.metadata",
+		"test-bucket/output/query-2.csv",
+		"test-bucket/output/query-2.csv.metadata",
+	}}, api.deletes)
+}
+
+func TestConn_CleanupResultsDisabled(t *testing.T) {
+	connector, api := newCleanupConnector(t, newFakeAthena(), DriverConfig{})
+	db := openTestConnector(t, connector)
+
+	_, err := db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+	require.NoError(t, connector.cleaner.flush(context.Background()))
+	assert.Empty(t, api.deletes)
+}
+
+func TestConn_CleanupResultsFailure(t *testing.T) {
+	hooks := &cleanupErrors{}
+	connector, api := newCleanupConnector(t, newFakeAthena(), DriverConfig{CleanupResults: true, Hooks: hooks})
+	api.failed = map[string]bool{"output/query-1.csv": true}
+	db := openTestConnector(t, connector)
+
+	_, err := db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+
+	// Shutting down deletes the results waiting to be cleaned up.
+	err = connector.Shutdown(context.Background())
+	assert.ErrorContains(t, err, "access denied")
+	assert.Equal(t, [][]string{{"test-bucket/output/query-1.csv.metadata"}}, api.deletes)
+
+	connector.hooks.wait()
+	assert.Equal(t, []string{"s3://test-bucket/output/query-1.csv"}, hooks.objects)
+}
+
+func TestResultCleaner_Batches(t *testing.T) {
+	api := &fakeS3{}
+	cleaner := newResultCleaner(api, nil, newLogger(nil))
+	cleaner.delay = time.Hour
+
+	for i := 0; i < maxDeleteObjects/2; i++ {
+		cleaner.add("s3://bucket/results/query.csv")
+	}
+	// A full batch is deleted right away.
+	require.NoError(t, cleaner.flush(context.Background()))
+	require.Len(t, api.deletes, 1)
+	assert.Len(t, api.deletes[0], maxDeleteObjects)
+}
+
+func TestPurgeResults(t *testing.T) {
+	now := time.Now()
+	api := &fakeS3{objects: []s3types.Object{
+		{Key: aws.String("output/old.csv"), LastModified: aws.Time(now.Add(-48 * time.Hour))},
+		{Key: aws.String("output/old.csv.metadata"), LastModified: aws.Time(now.Add(-48 * time.Hour))},
+		{Key: aws.String("output/recent.csv"), LastModified: aws.Time(now.Add(-time.Hour))},
+	}}
+
+	deleted, err := PurgeResults(context.Background(), api, "s3://test-bucket/output/", 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, [][]string{{"test-bucket/output/old.csv", "test-bucket/output/old.csv.metadata"}}, api.deletes)
+
+	_, err = PurgeResults(context.Background(), api, "/tmp/output", 24*time.Hour)
+	assert.Error(t, err)
+}
+
+func TestConnector_PurgeResults(t *testing.T) {
+	connector, api := newCleanupConnector(t, newFakeAthena(), DriverConfig{})
+	api.objects = []s3types.Object{
+		{Key: aws.String("output/old.csv"), LastModified: aws.Time(time.Now().Add(-48 * time.Hour))},
+	}
+
+	deleted, err := connector.PurgeResults(context.Background(), "s3://test-bucket/output/", 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, [][]string{{"test-bucket/output/old.csv"}}, api.deletes)
+}
+
+func TestParseS3URL(t *testing.T) {
+	bucket, key, err := parseS3URL("s3://bucket/path/to/query.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "path/to/query.csv", key)
+
+	_, _, err = parseS3URL("https://bucket/path")
+	assert.Error(t, err)
+}
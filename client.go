@@ -0,0 +1,229 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// QueryID is the ID Athena assigns to a query execution.
+// It can be persisted and used to pick up a query's results later,
+// possibly from another process.
+type QueryID string
+
+// ExecutionInfo describes the state of an Athena query execution.
+type ExecutionInfo struct {
+	QueryID           QueryID
+	Query             string
+	Database          string
+	Catalog           string
+	WorkGroup         string
+	StatementType     types.StatementType
+	State             types.QueryExecutionState
+	StateChangeReason string
+
+	// ErrorCategory is set for failed queries: 1 for system errors, 2 for
+	// user errors and 3 for others.
+	ErrorCategory int32
+
+	SubmittedAt time.Time
+	CompletedAt time.Time
+
+	DataScannedInBytes  int64
+	EngineExecutionTime time.Duration
+	QueryQueueTime      time.Duration
+	QueryPlanningTime   time.Duration
+	TotalExecutionTime  time.Duration
+
+	// ReusedPreviousResult is true if Athena answered the query with the
+	// results of an earlier execution. See ResultReuseConfig.
+	ReusedPreviousResult bool
+
+	// Priority is the priority of the query, and AdmissionWait the time it
+	// waited for DriverConfig.Limiter to let it run.
+	Priority      Priority
+	AdmissionWait time.Duration
+
+	// Shared is true if the query joined the execution of an identical query
+	// run concurrently. See DriverConfig.DeduplicateQueries.
+	Shared bool
+
+	// FromHistory is true if the results are those of an earlier execution
+	// found in the workgroup's history, which QueryID then identifies. See
+	// DriverConfig.HistoryReuse.
+	FromHistory bool
+
+	// Cached is true if the results were read from DriverConfig.ResultCache.
+	// The other fields then describe the execution that produced them.
+	Cached bool
+
+	// RuntimeStatistics is set for queries slower than
+	// DriverConfig.RuntimeStatisticsThreshold. It must not be modified.
+	RuntimeStatistics *RuntimeStatistics
+}
+
+func newExecutionInfo(qe *types.QueryExecution) *ExecutionInfo {
+	info := ExecutionInfo{
+		QueryID:       QueryID(aws.ToString(qe.QueryExecutionId)),
+		Query:         aws.ToString(qe.Query),
+		WorkGroup:     aws.ToString(qe.WorkGroup),
+		StatementType: qe.StatementType,
+	}
+
+	if qe.QueryExecutionContext != nil {
+		info.Database = aws.ToString(qe.QueryExecutionContext.Database)
+		info.Catalog = aws.ToString(qe.QueryExecutionContext.Catalog)
+	}
+
+	if status := qe.Status; status != nil {
+		info.State = status.State
+		info.StateChangeReason = aws.ToString(status.StateChangeReason)
+		info.SubmittedAt = aws.ToTime(status.SubmissionDateTime)
+		info.CompletedAt = aws.ToTime(status.CompletionDateTime)
+		if status.AthenaError != nil {
+			info.ErrorCategory = aws.ToInt32(status.AthenaError.ErrorCategory)
+		}
+	}
+
+	if stats := qe.Statistics; stats != nil {
+		info.DataScannedInBytes = aws.ToInt64(stats.DataScannedInBytes)
+		info.EngineExecutionTime = millis(stats.EngineExecutionTimeInMillis)
+		info.QueryQueueTime = millis(stats.QueryQueueTimeInMillis)
+		info.QueryPlanningTime = millis(stats.QueryPlanningTimeInMillis)
+		info.TotalExecutionTime = millis(stats.TotalExecutionTimeInMillis)
+		if stats.ResultReuseInformation != nil {
+			info.ReusedPreviousResult = stats.ResultReuseInformation.ReusedPreviousResult
+		}
+	}
+
+	return &info
+}
+
+func millis(ms *int64) time.Duration {
+	return time.Duration(aws.ToInt64(ms)) * time.Millisecond
+}
+
+type executionInfoKey struct{}
+
+// WithExecutionInfo returns a context that makes queries run with it store
+// their final ExecutionInfo in info once they finish, whether they succeeded
+// or not.
+func WithExecutionInfo(ctx context.Context, info *ExecutionInfo) context.Context {
+	return context.WithValue(ctx, executionInfoKey{}, info)
+}
+
+// recordExecutionInfo stores info in the ExecutionInfo requested through ctx, if any.
+func recordExecutionInfo(ctx context.Context, info *ExecutionInfo) {
+	if dest, ok := ctx.Value(executionInfoKey{}).(*ExecutionInfo); ok && dest != nil {
+		*dest = *info
+	}
+}
+
+type queryIDKey struct{}
+
+// WithQueryID returns a context that makes `db.QueryContext` read the results
+// of an existing query execution instead of starting a new one. The query
+// string passed alongside it is ignored. If the execution is still running,
+// the driver waits for it to finish, but does not stop it if ctx is canceled.
+func WithQueryID(ctx context.Context, id QueryID) context.Context {
+	return context.WithValue(ctx, queryIDKey{}, id)
+}
+
+func queryIDFromContext(ctx context.Context) (QueryID, bool) {
+	id, ok := ctx.Value(queryIDKey{}).(QueryID)
+	return id, ok && id != ""
+}
+
+// Client exposes the asynchronous lifecycle of Athena queries: a query can be
+// started, its ID persisted, and its results read later, even from another
+// process.
+//
+// A Client wraps a *sql.DB opened with this driver, e.g. through `sql.Open("athena", ...)`
+// or `athena.Open()`, and uses that DB's configuration.
+type Client struct {
+	db *sql.DB
+}
+
+// NewClient returns a Client running queries through db.
+func NewClient(db *sql.DB) *Client {
+	return &Client{db: db}
+}
+
+// Start submits a query and returns its ID without waiting for it to finish.
+// It honors the QueryOptions carried by ctx, except for ResultMode, Timeout
+// and MaxBytesScanned.
+func (c *Client) Start(ctx context.Context, query string) (QueryID, error) {
+	var id QueryID
+	err := c.withConn(ctx, func(cn *conn) error {
+		queryID, err := cn.startQuery(ctx, query, cn.queryOptions(ctx))
+		id = QueryID(queryID)
+		return err
+	})
+	return id, err
+}
+
+// Status returns the current state of a query execution.
+func (c *Client) Status(ctx context.Context, id QueryID) (*ExecutionInfo, error) {
+	var info *ExecutionInfo
+	err := c.withConn(ctx, func(cn *conn) error {
+		resp, err := cn.athena.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{
+			QueryExecutionId: aws.String(string(id)),
+		})
+		if err != nil {
+			return err
+		}
+
+		info = newExecutionInfo(resp.QueryExecution)
+		return nil
+	})
+	return info, err
+}
+
+// Wait blocks until a query execution finishes, returning an error if it
+// failed or was canceled. Unlike a query run through `db.Query`, the
+// execution keeps running in Athena if ctx is canceled.
+func (c *Client) Wait(ctx context.Context, id QueryID) (*ExecutionInfo, error) {
+	var info *ExecutionInfo
+	err := c.withConn(ctx, func(cn *conn) error {
+		qe, err := cn.pollQuery(ctx, string(id), nil)
+		if qe != nil {
+			info = newExecutionInfo(qe)
+		}
+		cn.hooks.onFinish(string(id), err, info)
+		return err
+	})
+	return info, err
+}
+
+// Results waits for a query execution to finish and returns its results.
+func (c *Client) Results(ctx context.Context, id QueryID) (*sql.Rows, error) {
+	return c.db.QueryContext(WithQueryID(ctx, id), "")
+}
+
+// withConn runs fn with a driver connection from the client's pool.
+func (c *Client) withConn(ctx context.Context, fn func(*conn) error) error {
+	return withConn(ctx, c.db, fn)
+}
+
+// withConn runs fn with a driver connection from the pool of db, which must
+// be an Athena database.
+func withConn(ctx context.Context, db *sql.DB, fn func(*conn) error) error {
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close()
+
+	return sqlConn.Raw(func(driverConn interface{}) error {
+		cn, ok := driverConn.(*conn)
+		if !ok {
+			return errors.New("athena: the DB must be opened with the athena driver")
+		}
+		return fn(cn)
+	})
+}
@@ -0,0 +1,57 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeAthena(types.QueryExecutionStateQueued, types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	client := NewClient(openTestDB(t, fake))
+
+	id, err := client.Start(ctx, "select")
+	require.NoError(t, err)
+	assert.Equal(t, QueryID("query-1"), id)
+
+	info, err := client.Status(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, types.QueryExecutionStateQueued, info.State)
+	assert.Equal(t, "select", info.Query)
+
+	info, err = client.Wait(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, types.QueryExecutionStateSucceeded, info.State)
+
+	rows, err := client.Results(ctx, id)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	cnt := 0
+	for rows.Next() {
+		cnt++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 9, cnt)
+	assert.Len(t, fake.started, 1, "reading results must not start a new query")
+}
+
+func TestClient_WaitDoesNotStop(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	client := NewClient(openTestDB(t, fake))
+
+	id, err := client.Start(context.Background(), "select")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = client.Wait(ctx, id)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, fake.stopped)
+}
@@ -0,0 +1,105 @@
+package athena
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Athena returns values of complex types as text, e.g. "[1, 2, 3]" for an
+// array and "{a=1, b=2}" for a map or a row, without quoting strings. The
+// functions below split such text into the text of the elements. Strings
+// containing ", " or "=" at the top level of a value can't be told apart
+// from separators, and are split incorrectly.
+
+// complexNull is the text of NULL elements of complex values.
+const complexNull = "null"
+
+// complexEntry is an entry of a map, or a field of a row.
+type complexEntry struct {
+	key   string
+	value *string
+}
+
+// parseArray splits the text of an array into the text of its elements. NULL
+// elements are nil.
+func parseArray(s string) ([]*string, error) {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("cannot parse '%s' as array", s)
+	}
+
+	var elems []*string
+	for _, elem := range splitComplex(s[1 : len(s)-1]) {
+		elems = append(elems, complexElement(elem))
+	}
+	return elems, nil
+}
+
+// parseMap splits the text of a map or row into its entries, in order. NULL
+// values are nil.
+func parseMap(s string) ([]complexEntry, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, fmt.Errorf("cannot parse '%s' as map", s)
+	}
+
+	var entries []complexEntry
+	for _, elem := range splitComplex(s[1 : len(s)-1]) {
+		key, value, ok := cutComplex(elem)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse '%s' as map entry", elem)
+		}
+		entries = append(entries, complexEntry{key: key, value: complexElement(value)})
+	}
+	return entries, nil
+}
+
+func complexElement(s string) *string {
+	if s == complexNull {
+		return nil
+	}
+	return &s
+}
+
+// splitComplex splits s on the ", " separators that aren't nested in an
+// array, map or row.
+func splitComplex(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var elems []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 && strings.HasPrefix(s[i:], ", ") {
+				elems = append(elems, s[start:i])
+				start = i + 2
+				i++
+			}
+		}
+	}
+	return append(elems, s[start:])
+}
+
+// cutComplex splits an entry of a map on the first "=" that isn't nested in
+// its key.
+func cutComplex(s string) (key, value string, ok bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case '=':
+			if depth == 0 {
+				return s[:i], s[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
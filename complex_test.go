@@ -0,0 +1,51 @@
+package athena
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArray(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []*string
+	}{
+		{"[]", nil},
+		{"[1]", []*string{aws.String("1")}},
+		{"[a, null, c]", []*string{aws.String("a"), nil, aws.String("c")}},
+		{"[[1, 2], [3]]", []*string{aws.String("[1, 2]"), aws.String("[3]")}},
+		{"[{a=1, b=2}, {a=3, b=4}]", []*string{aws.String("{a=1, b=2}"), aws.String("{a=3, b=4}")}},
+	}
+	for _, test := range tests {
+		elems, err := parseArray(test.text)
+		require.NoError(t, err, test.text)
+		assert.Equal(t, test.expected, elems, test.text)
+	}
+
+	_, err := parseArray("{a=1}")
+	assert.EqualError(t, err, "cannot parse '{a=1}' as array")
+}
+
+func TestParseMap(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []complexEntry
+	}{
+		{"{}", nil},
+		{"{a=1, b=null}", []complexEntry{{"a", aws.String("1")}, {"b", nil}}},
+		{"{x=[1, 2], y={z=a=b}}", []complexEntry{{"x", aws.String("[1, 2]")}, {"y", aws.String("{z=a=b}")}}},
+	}
+	for _, test := range tests {
+		entries, err := parseMap(test.text)
+		require.NoError(t, err, test.text)
+		assert.Equal(t, test.expected, entries, test.text)
+	}
+
+	_, err := parseMap("{a}")
+	assert.EqualError(t, err, "cannot parse 'a' as map entry")
+	_, err = parseMap("[1]")
+	assert.EqualError(t, err, "cannot parse '[1]' as map")
+}
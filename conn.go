@@ -4,19 +4,84 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/athena"
 	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	uuid "github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultStartAttempts = 3
+	maxStartBackoff      = 20 * time.Second
+)
+
+var (
+	isRetryableError  = retry.IsErrorRetryables(retry.DefaultRetryables)
+	isThrottlingError = retry.IsErrorThrottles(retry.DefaultThrottles)
 )
 
 type conn struct {
 	athena         athenaAPI
 	db             string
+	catalog        string
+	workGroup      string
 	OutputLocation string
 
-	pollFrequency time.Duration
+	pollFrequency  time.Duration
+	pageSize       int
+	progress       ProgressFunc
+	statsThreshold time.Duration
+	resultReuse    ResultReuseConfig
+	historyReuse   HistoryReuseConfig
+
+	maxBytesScanned    int64
+	checkScanEstimates bool
+
+	// startAttempts bounds how many times StartQueryExecution is attempted
+	// once the SDK's own retries are exhausted, waiting for backoff between
+	// attempts. backoff also paces polling when the API is throttled.
+	startAttempts int
+	backoff       retry.BackoffDelayer
+	limiter       *Limiter
+
+	queries         *inFlightQueries
+	shutdownTimeout time.Duration
+	flights         *flightGroup
+	cache           ResultCache
+	cleaner         *resultCleaner
+
+	costs  *costAccountant
+	tracer trace.Tracer
+	hooks  *hookDispatcher
+	logger *slog.Logger
+
+	// parent is the connection a silent copy was made of.
+	parent *conn
+}
+
+// silent returns a copy of c that doesn't report the queries it runs to the
+// hooks, logs or progress callbacks, for queries the driver runs on its own
+// behalf. Its queries belong to c as far as Close is concerned.
+func (c *conn) silent() *conn {
+	s := *c
+	s.progress = nil
+	s.hooks = nil
+	s.logger = newLogger(nil)
+	s.parent = c
+	return &s
+}
+
+// detached returns a copy of c whose queries don't belong to c, so that
+// closing c doesn't stop them.
+func (c *conn) detached() *conn {
+	d := *c
+	d.parent = nil
+	return &d
 }
 
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
@@ -33,81 +98,477 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		panic("The go-athena driver doesn't support prepared statements yet. Format your own arguments.")
 	}
 
-	_, err := c.runQuery(ctx, query)
-	return nil, err
-}
-
-func (c *conn) runQuery(ctx context.Context, query string) (driver.Rows, error) {
-	queryID, err := c.startQuery(ctx, query)
+	rows, err := c.runQuery(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	return nil, rows.Close()
+}
 
-	if err := c.waitOnQuery(ctx, queryID); err != nil {
+func (c *conn) runQuery(ctx context.Context, query string) (_ driver.Rows, err error) {
+	opts := c.queryOptions(ctx)
+	if err := validatePageSize(opts.PageSize); err != nil {
 		return nil, err
 	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	ctx, span := c.tracer.Start(ctx, "athena.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrDBSystem.String("athena"),
+			attrDatabase.String(opts.Database),
+			attrWorkGroup.String(opts.WorkGroup),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+
+	var ex execution
+	var cache *cacheWriter
+	var limit int
+	if id, ok := queryIDFromContext(ctx); ok {
+		// The query was started elsewhere, so it's not ours to stop.
+		ex.queryID = string(id)
+		ex.qe, ex.err = c.pollQuery(ctx, ex.queryID, nil)
+	} else {
+		limit, _ = queryLimit(query)
+		if c.queries.isClosed() {
+			return nil, ErrShutdown
+		}
+
+		key, cacheable := c.resultCacheKey(query, opts)
+		if cacheable && !opts.NoCache {
+			if result, ok := c.cache.Get(key); ok {
+				return c.cachedRows(ctx, result), nil
+			}
+		}
+
+		if qe, ok := c.findInHistory(ctx, query, opts); ok {
+			ex = execution{queryID: aws.ToString(qe.QueryExecutionId), qe: qe, fromHistory: true}
+		} else if c.flights != nil {
+			// The execution outlives the connection of the query starting
+			// it, so it belongs to the connector: closing the connection
+			// doesn't stop it, only a shutdown does.
+			shared := c.detached()
+			ex = c.flights.do(ctx, c.flightKey(ctx, query, opts), func(ctx context.Context) execution {
+				// Report the execution once, rather than once per query
+				// sharing it, even if they all gave up on it.
+				ex := shared.execute(ctx, query, opts)
+				ex.info = shared.executionInfo(ctx, ex)
+				shared.hooks.onFinish(ex.queryID, ex.err, ex.info)
+				ex.reported = true
+				return ex
+			})
+		} else {
+			ex = c.execute(ctx, query, opts)
+		}
+
+		if cacheable && ex.err == nil && ex.qe != nil {
+			cache = newCacheWriter(c.cache, key, newExecutionInfo(ex.qe))
+		}
+	}
+	if ex.queryID == "" {
+		return nil, ex.err
+	}
+
+	c.finishQuery(ctx, ex)
+	cleanup := c.resultsCleanup(ex)
+	if ex.err != nil {
+		cleanup()
+		return nil, ex.err
+	}
 
 	return newRows(ctx, rowsConfig{
-		Athena:  c.athena,
-		QueryID: queryID,
-		// todo add check for ddl queries to not skip header(#10)
-		SkipHeader: true,
+		Athena:     c.athena,
+		QueryID:    ex.queryID,
+		SkipHeader: opts.ResultMode.skipHeader(ex.qe),
+		Tracer:     c.tracer,
+		Hooks:      c.hooks,
+		Logger:     c.logger,
+		Cache:      cache,
+		Cleanup:    cleanup,
+		PageSize:   opts.PageSize,
+		Limit:      limit,
 	})
 }
 
+// resultsCleanup returns a func deleting the results of ex, if the driver
+// is configured to and they're not read by anyone else.
+func (c *conn) resultsCleanup(ex execution) func() {
+	if c.cleaner == nil || !ex.owned || ex.qe == nil || ex.qe.ResultConfiguration == nil {
+		return func() {}
+	}
+
+	outputLocation := aws.ToString(ex.qe.ResultConfiguration.OutputLocation)
+	return func() { c.cleaner.add(outputLocation) }
+}
+
+// execution is the outcome of running a query.
+type execution struct {
+	// queryID is empty if the query wasn't started, err telling why.
+	queryID string
+	qe      *types.QueryExecution
+	err     error
+
+	admissionWait time.Duration
+
+	// shared is set if the execution was shared with an identical query,
+	// fromHistory if it's an earlier execution found in the history.
+	shared      bool
+	fromHistory bool
+
+	// owned is set if the caller is the only one reading the results.
+	owned bool
+
+	// reported is set once the execution was reported to the hooks, info
+	// being what they were passed.
+	reported bool
+	info     *ExecutionInfo
+}
+
+// execute starts a query and waits for it to finish.
+func (c *conn) execute(ctx context.Context, query string, opts QueryOptions) (ex execution) {
+	if c.limiter != nil {
+		priority := priorityFromContext(ctx)
+		waitingSince := time.Now()
+		release, err := c.limiter.acquire(ctx, priority)
+		ex.admissionWait = time.Since(waitingSince)
+		trace.SpanFromContext(ctx).SetAttributes(
+			attrPriority.String(priority.String()),
+			attrAdmissionWait.Int64(ex.admissionWait.Milliseconds()),
+		)
+		if err != nil {
+			ex.err = err
+			return ex
+		}
+		defer release()
+	}
+
+	if c.checkScanEstimates {
+		if err := c.checkScanEstimate(ctx, query, opts); err != nil {
+			ex.err = err
+			return ex
+		}
+	}
+
+	if c.queries.isClosed() {
+		ex.err = ErrShutdown
+		return ex
+	}
+
+	queryID, err := c.startQuery(ctx, query, opts)
+	if err != nil {
+		ex.err = err
+		return ex
+	}
+
+	if !c.queries.add(queryID, c) {
+		// The connector was shut down while the query started.
+		c.stopQuery(context.WithoutCancel(ctx), queryID, ErrShutdown)
+		ex.err = ErrShutdown
+		return ex
+	}
+
+	ex.queryID = queryID
+	ex.owned = true
+	ex.qe, ex.err = c.waitOnQuery(ctx, queryID, opts)
+	if !c.queries.remove(queryID) && ex.err != nil {
+		ex.err = ErrShutdown
+	}
+	c.costs.record(labelsFromContext(ctx), ex.qe)
+	return ex
+}
+
+// finishQuery reports the outcome of a query the driver waited on.
+func (c *conn) finishQuery(ctx context.Context, ex execution) {
+	if ex.qe != nil {
+		trace.SpanFromContext(ctx).SetAttributes(executionAttributes(ex.qe)...)
+	}
+
+	info := ex.info
+	if !ex.reported {
+		info = c.executionInfo(ctx, ex)
+		c.hooks.onFinish(ex.queryID, ex.err, info)
+	} else if info != nil {
+		shared := *info
+		shared.Priority = priorityFromContext(ctx)
+		shared.Shared = ex.shared
+		info = &shared
+	}
+	if info != nil {
+		recordExecutionInfo(ctx, info)
+	}
+}
+
+// executionInfo describes ex, run through ctx. It's nil if the query was
+// never polled.
+func (c *conn) executionInfo(ctx context.Context, ex execution) *ExecutionInfo {
+	if ex.qe == nil {
+		return nil
+	}
+
+	info := newExecutionInfo(ex.qe)
+	info.Priority = priorityFromContext(ctx)
+	info.AdmissionWait = ex.admissionWait
+	info.Shared = ex.shared
+	info.FromHistory = ex.fromHistory
+	if ex.err == nil && c.statsThreshold > 0 && info.TotalExecutionTime >= c.statsThreshold {
+		// The statistics are a diagnostic aid: failing to get them
+		// mustn't fail the query.
+		info.RuntimeStatistics, _ = c.runtimeStatistics(ctx, ex.queryID)
+	}
+	return info
+}
+
+// queryOptions returns the connection's configuration with the overrides
+// carried by ctx applied.
+func (c *conn) queryOptions(ctx context.Context) QueryOptions {
+	opts := QueryOptions{
+		Database:        c.db,
+		Catalog:         c.catalog,
+		WorkGroup:       c.workGroup,
+		OutputLocation:  c.OutputLocation,
+		MaxBytesScanned: c.maxBytesScanned,
+		PageSize:        c.pageSize,
+	}
+	if override, ok := queryOptionsFromContext(ctx); ok {
+		opts = opts.merge(override)
+	}
+	return opts
+}
+
 // startQuery starts an Athena query and returns its ID.
-func (c *conn) startQuery(ctx context.Context, query string) (string, error) {
-	resp, err := c.athena.StartQueryExecution(ctx, &athena.StartQueryExecutionInput{
+//
+// Every attempt to start the query carries the same client request token,
+// either opts.ClientRequestToken or a generated one, so that Athena returns
+// the same execution if an earlier attempt reached it despite failing.
+func (c *conn) startQuery(ctx context.Context, query string, opts QueryOptions) (queryID string, err error) {
+	ctx, span := c.tracer.Start(ctx, "athena.StartQueryExecution", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		span.SetAttributes(attrQueryID.String(queryID))
+		endSpan(span, err)
+	}()
+
+	resultReuse := c.resultReuse
+	if override, ok := resultReuseFromContext(ctx); ok {
+		if err := override.validate(); err != nil {
+			return "", err
+		}
+		resultReuse = override
+	}
+
+	input := &athena.StartQueryExecutionInput{
 		QueryString: aws.String(query),
 		QueryExecutionContext: &types.QueryExecutionContext{
-			Database: aws.String(c.db),
+			Database: aws.String(opts.Database),
 		},
 		ResultConfiguration: &types.ResultConfiguration{
-			OutputLocation: aws.String(c.OutputLocation),
+			EncryptionConfiguration: opts.Encryption,
 		},
-	})
-	if err != nil {
-		return "", err
+		ResultReuseConfiguration: resultReuse.apiConfig(),
 	}
+	if opts.Catalog != "" {
+		input.QueryExecutionContext.Catalog = aws.String(opts.Catalog)
+	}
+	if opts.WorkGroup != "" {
+		input.WorkGroup = aws.String(opts.WorkGroup)
+	}
+	if opts.OutputLocation != "" {
+		location, err := outputLocation(ctx, opts, time.Now())
+		if err != nil {
+			return "", err
+		}
+		input.ResultConfiguration.OutputLocation = aws.String(location)
+	}
+	if opts.ClientRequestToken != "" {
+		input.ClientRequestToken = aws.String(opts.ClientRequestToken)
+	} else {
+		input.ClientRequestToken = aws.String(uuid.NewV4().String())
+	}
+
+	attempts := c.startAttempts
+	if attempts <= 0 {
+		attempts = defaultStartAttempts
+	}
+	backoff := c.backoffDelayer()
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(attrStartAttempts.Int(attempt))
+		resp, err := c.athena.StartQueryExecution(ctx, input)
+		if err == nil {
+			c.logger.LogAttrs(ctx, slog.LevelInfo, "athena: query started",
+				slog.String("query_id", *resp.QueryExecutionId),
+				slog.String("query", redactQuery(query)),
+				slog.String("database", opts.Database),
+				slog.String("workgroup", opts.WorkGroup),
+			)
+			c.hooks.onStart(*resp.QueryExecutionId, query)
+			return *resp.QueryExecutionId, nil
+		}
+
+		if attempt >= attempts || isRetryableError.IsErrorRetryable(err) != aws.TrueTernary {
+			return "", err
+		}
+
+		delay, backoffErr := backoff.BackoffDelay(attempt, err)
+		if backoffErr != nil {
+			return "", err
+		}
+
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "athena: retrying query start",
+			slog.String("query", redactQuery(query)),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("error", err),
+		)
 
-	return *resp.QueryExecutionId, nil
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *conn) backoffDelayer() retry.BackoffDelayer {
+	if c.backoff == nil {
+		return retry.NewExponentialJitterBackoff(maxStartBackoff)
+	}
+	return c.backoff
 }
 
 // waitOnQuery blocks until a query finishes, returning an error if it failed.
-func (c *conn) waitOnQuery(ctx context.Context, queryID string) error {
+// The query is stopped if it's abandoned before it finishes, because ctx is
+// done or it went over opts.MaxBytesScanned.
+func (c *conn) waitOnQuery(ctx context.Context, queryID string, opts QueryOptions) (qe *types.QueryExecution, err error) {
+	ctx, span := c.tracer.Start(ctx, "athena.wait", trace.WithAttributes(attrQueryID.String(queryID)))
+	defer func() {
+		if qe != nil {
+			span.SetAttributes(executionAttributes(qe)...)
+		}
+		endSpan(span, err)
+	}()
+
+	qe, err = c.pollQuery(ctx, queryID, scanBudgetCheck(opts.MaxBytesScanned))
+	if err != nil && !isFinished(qe) {
+		// ctx may be done already, so the stop request must not depend on it.
+		c.stopQuery(context.WithoutCancel(ctx), queryID, err)
+	}
+
+	return qe, err
+}
+
+// stopQuery stops a query for reason, reporting it to the hooks.
+func (c *conn) stopQuery(ctx context.Context, queryID string, reason error) error {
+	_, err := c.athena.StopQueryExecution(ctx, &athena.StopQueryExecutionInput{
+		QueryExecutionId: aws.String(queryID),
+	})
+	c.logger.LogAttrs(ctx, slog.LevelWarn, "athena: query stopped",
+		slog.String("query_id", queryID),
+		slog.Any("reason", reason),
+	)
+	c.hooks.onCancel(queryID, reason)
+	return err
+}
+
+// pollQuery blocks until a query finishes, returning its last known execution
+// and an error if it failed. If check is set, it's called on every poll of
+// an unfinished query and polling stops with the error it returns, if any.
+// State changes are recorded as events of the span in ctx, and reported to
+// the hooks. Progress is reported on every poll if requested.
+func (c *conn) pollQuery(ctx context.Context, queryID string, check func(*types.QueryExecution) error) (*types.QueryExecution, error) {
+	var lastState types.QueryExecutionState
+	progress := c.progressFunc(ctx)
+	polledSince := time.Now()
+	throttled := 0
 	for {
 		statusResp, err := c.athena.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{
 			QueryExecutionId: aws.String(queryID),
 		})
+		if err != nil && isThrottlingError.IsErrorThrottle(err) == aws.TrueTernary {
+			// The query keeps running regardless, so keep polling it, only
+			// slower.
+			throttled++
+			delay, backoffErr := c.backoffDelayer().BackoffDelay(throttled, err)
+			if backoffErr != nil {
+				return nil, err
+			}
+
+			c.logger.LogAttrs(ctx, slog.LevelWarn, "athena: polling throttled",
+				slog.String("query_id", queryID),
+				slog.Duration("delay", delay),
+			)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+				continue
+			}
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
+		throttled = 0
 
-		switch statusResp.QueryExecution.Status.State {
+		qe := statusResp.QueryExecution
+		if qe.Status.State != lastState {
+			lastState = qe.Status.State
+			recordStateChange(ctx, qe)
+			c.hooks.onStateChange(qe)
+			c.logger.LogAttrs(ctx, slog.LevelDebug, "athena: query state changed",
+				slog.String("query_id", queryID),
+				slog.String("state", string(lastState)),
+				slog.Int64("data_scanned_bytes", dataScannedInBytes(qe)),
+			)
+		}
+
+		if progress != nil {
+			c.reportProgress(ctx, progress, qe, polledSince)
+		}
+
+		switch qe.Status.State {
 		case types.QueryExecutionStateCancelled:
-			return context.Canceled
+			return qe, context.Canceled
 		case types.QueryExecutionStateFailed:
-			reason := *statusResp.QueryExecution.Status.StateChangeReason
-			return errors.New(reason)
+			reason := aws.ToString(qe.Status.StateChangeReason)
+			return qe, errors.New(reason)
 		case types.QueryExecutionStateSucceeded:
-			return nil
+			return qe, nil
 		case types.QueryExecutionStateQueued:
 		case types.QueryExecutionStateRunning:
 		}
 
+		if check != nil {
+			if err := check(qe); err != nil {
+				return qe, err
+			}
+		}
+
 		select {
 		case <-ctx.Done():
-			c.athena.StopQueryExecution(ctx, &athena.StopQueryExecutionInput{
-				QueryExecutionId: aws.String(queryID),
-			})
-
-			return ctx.Err()
+			return qe, ctx.Err()
 		case <-time.After(c.pollFrequency):
 			continue
 		}
 	}
 }
 
+// isFinished reports whether qe reached a final state.
+func isFinished(qe *types.QueryExecution) bool {
+	if qe == nil || qe.Status == nil {
+		return false
+	}
+
+	switch qe.Status.State {
+	case types.QueryExecutionStateSucceeded, types.QueryExecutionStateFailed, types.QueryExecutionStateCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	panic("The go-athena driver doesn't support prepared statements yet")
 }
@@ -116,8 +577,17 @@ func (c *conn) Begin() (driver.Tx, error) {
 	panic("Athena doesn't support transactions")
 }
 
+// Close stops the queries the connection is waiting on, if any, waiting up to
+// DriverConfig.ShutdownTimeout.
 func (c *conn) Close() error {
-	return nil
+	queries := c.queries.take(c, false)
+	if len(queries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.shutdownTimeout)
+	defer cancel()
+	return stopQueries(ctx, queries)
 }
 
 var _ driver.QueryerContext = (*conn)(nil)
@@ -0,0 +1,423 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAthena is an in-memory athenaAPI. Started queries run through states,
+// one per GetQueryExecution call, and serve results from queryToResultsGenMap
+// keyed by their query string.
+type fakeAthena struct {
+	mu      sync.Mutex
+	states  []types.QueryExecutionState
+	execs   map[string]*fakeExecution
+	started []*athena.StartQueryExecutionInput
+	stopped []string
+	tokens  map[string]string
+
+	// startErrs are returned by successive StartQueryExecution calls after
+	// the execution was registered, as if the response was lost.
+	startErrs []error
+
+	// pollErrs are returned by successive GetQueryExecution calls.
+	pollErrs []error
+
+	// hold, if set, blocks GetQueryExecution calls until it's closed.
+	hold chan struct{}
+
+	// history are past executions, most recent first, listed before the
+	// queries started.
+	history []types.QueryExecution
+	listed  int
+
+	// results are the GetQueryResults calls made.
+	results []*athena.GetQueryResultsInput
+}
+
+type fakeExecution struct {
+	input *athena.StartQueryExecutionInput
+	polls int
+	state types.QueryExecutionState
+}
+
+func newFakeAthena(states ...types.QueryExecutionState) *fakeAthena {
+	if len(states) == 0 {
+		states = []types.QueryExecutionState{types.QueryExecutionStateSucceeded}
+	}
+	return &fakeAthena{states: states, execs: map[string]*fakeExecution{}, tokens: map[string]string{}}
+}
+
+func (f *fakeAthena) StartQueryExecution(ctx context.Context, in *athena.StartQueryExecutionInput, opts ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.started = append(f.started, in)
+	id, ok := f.tokens[aws.ToString(in.ClientRequestToken)]
+	if !ok {
+		id = fmt.Sprintf("query-%d", len(f.execs)+1)
+		f.execs[id] = &fakeExecution{input: in, state: types.QueryExecutionStateQueued}
+		if in.ClientRequestToken != nil {
+			f.tokens[*in.ClientRequestToken] = id
+		}
+	}
+
+	if len(f.startErrs) > 0 {
+		err := f.startErrs[0]
+		f.startErrs = f.startErrs[1:]
+		return nil, err
+	}
+	return &athena.StartQueryExecutionOutput{QueryExecutionId: aws.String(id)}, nil
+}
+
+func (f *fakeAthena) GetQueryExecution(ctx context.Context, in *athena.GetQueryExecutionInput, opts ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error) {
+	if f.hold != nil {
+		<-f.hold
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.pollErrs) > 0 {
+		err := f.pollErrs[0]
+		f.pollErrs = f.pollErrs[1:]
+		return nil, err
+	}
+
+	id := aws.ToString(in.QueryExecutionId)
+	exec, ok := f.execs[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown query %s", id)
+	}
+
+	if exec.state != types.QueryExecutionStateCancelled {
+		exec.state = f.states[min(exec.polls, len(f.states)-1)]
+		exec.polls++
+	}
+
+	qe := &types.QueryExecution{
+		QueryExecutionId:      aws.String(id),
+		Query:                 exec.input.QueryString,
+		QueryExecutionContext: exec.input.QueryExecutionContext,
+		ResultConfiguration: &types.ResultConfiguration{
+			OutputLocation: aws.String(aws.ToString(exec.input.ResultConfiguration.OutputLocation) + "/" + id + ".csv"),
+		},
+		WorkGroup:     exec.input.WorkGroup,
+		StatementType: types.StatementTypeDml,
+		Status: &types.QueryExecutionStatus{
+			State:              exec.state,
+			SubmissionDateTime: aws.Time(time.Unix(0, 0)),
+		},
+		Statistics: &types.QueryExecutionStatistics{
+			DataScannedInBytes:          aws.Int64(int64(exec.polls) * 1024),
+			QueryQueueTimeInMillis:      aws.Int64(100),
+			EngineExecutionTimeInMillis: aws.Int64(int64(exec.polls) * 1000),
+			TotalExecutionTimeInMillis:  aws.Int64(100 + int64(exec.polls)*1000),
+		},
+	}
+	if reuse := exec.input.ResultReuseConfiguration; reuse != nil {
+		// Pretend an identical query ran recently.
+		qe.Statistics.ResultReuseInformation = &types.ResultReuseInformation{
+			ReusedPreviousResult: reuse.ResultReuseByAgeConfiguration.Enabled,
+		}
+	}
+	if exec.state == types.QueryExecutionStateFailed {
+		qe.Status.StateChangeReason = aws.String("query failed")
+		qe.Status.AthenaError = &types.AthenaError{ErrorCategory: aws.Int32(2)}
+	}
+	return &athena.GetQueryExecutionOutput{QueryExecution: qe}, nil
+}
+
+func (f *fakeAthena) GetQueryResults(ctx context.Context, in *athena.GetQueryResultsInput, opts ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error) {
+	f.mu.Lock()
+	exec, ok := f.execs[aws.ToString(in.QueryExecutionId)]
+	f.results = append(f.results, in)
+	f.mu.Unlock()
+	if !ok {
+		for _, qe := range f.history {
+			if aws.ToString(qe.QueryExecutionId) == aws.ToString(in.QueryExecutionId) {
+				return queryToResultsGenMap[aws.ToString(qe.Query)](aws.ToString(in.NextToken))
+			}
+		}
+		return nil, dummyError
+	}
+
+	return queryToResultsGenMap[aws.ToString(exec.input.QueryString)](aws.ToString(in.NextToken))
+}
+
+func (f *fakeAthena) ListQueryExecutions(ctx context.Context, in *athena.ListQueryExecutionsInput, opts ...func(*athena.Options)) (*athena.ListQueryExecutionsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listed++
+
+	offset, _ := strconv.Atoi(aws.ToString(in.NextToken))
+	end := min(offset+int(aws.ToInt32(in.MaxResults)), len(f.history))
+	out := &athena.ListQueryExecutionsOutput{}
+	for _, qe := range f.history[offset:end] {
+		out.QueryExecutionIds = append(out.QueryExecutionIds, aws.ToString(qe.QueryExecutionId))
+	}
+	if end < len(f.history) {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func (f *fakeAthena) BatchGetQueryExecution(ctx context.Context, in *athena.BatchGetQueryExecutionInput, opts ...func(*athena.Options)) (*athena.BatchGetQueryExecutionOutput, error) {
+	out := &athena.BatchGetQueryExecutionOutput{}
+	for _, id := range in.QueryExecutionIds {
+		for _, qe := range f.history {
+			if aws.ToString(qe.QueryExecutionId) == id {
+				out.QueryExecutions = append(out.QueryExecutions, qe)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeAthena) GetQueryRuntimeStatistics(ctx context.Context, in *athena.GetQueryRuntimeStatisticsInput, opts ...func(*athena.Options)) (*athena.GetQueryRuntimeStatisticsOutput, error) {
+	return &athena.GetQueryRuntimeStatisticsOutput{
+		QueryRuntimeStatistics: &types.QueryRuntimeStatistics{
+			Rows: &types.QueryRuntimeStatisticsRows{
+				InputRows:  aws.Int64(100),
+				OutputRows: aws.Int64(9),
+			},
+			OutputStage: &types.QueryStage{
+				StageId:       aws.Int64(0),
+				State:         aws.String("RUNNING"),
+				ExecutionTime: aws.Int64(20),
+				OutputRows:    aws.Int64(9),
+				QueryStagePlan: &types.QueryStagePlanNode{
+					Name:       aws.String("Output"),
+					Identifier: aws.String("1"),
+					Children: []types.QueryStagePlanNode{
+						{Name: aws.String("Limit"), Identifier: aws.String("2")},
+					},
+				},
+				SubStages: []types.QueryStage{{
+					StageId:       aws.Int64(1),
+					State:         aws.String("FINISHED"),
+					ExecutionTime: aws.Int64(10),
+					InputRows:     aws.Int64(100),
+					InputBytes:    aws.Int64(2048),
+					OutputRows:    aws.Int64(9),
+				}},
+			},
+		},
+	}, nil
+}
+
+// startedCount returns the number of StartQueryExecution calls so far.
+func (f *fakeAthena) startedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.started)
+}
+
+func (f *fakeAthena) StopQueryExecution(ctx context.Context, in *athena.StopQueryExecutionInput, opts ...func(*athena.Options)) (*athena.StopQueryExecutionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := aws.ToString(in.QueryExecutionId)
+	f.stopped = append(f.stopped, id)
+	if exec, ok := f.execs[id]; ok {
+		exec.state = types.QueryExecutionStateCancelled
+	}
+	return &athena.StopQueryExecutionOutput{}, nil
+}
+
+type noBackoff struct{}
+
+func (noBackoff) BackoffDelay(int, error) (time.Duration, error) {
+	return 0, nil
+}
+
+// newTestConnector returns a Connector for cfg backed by a fake athenaAPI.
+func newTestConnector(t *testing.T, api athenaAPI, cfg DriverConfig) *Connector {
+	cfg.Config = &aws.Config{}
+	if cfg.Database == "" {
+		cfg.Database = "test_db"
+	}
+	if cfg.OutputLocation == "" {
+		cfg.OutputLocation = "s3://test-bucket/output"
+	}
+	if cfg.PollFrequency == 0 {
+		cfg.PollFrequency = time.Millisecond
+	}
+
+	connector, err := NewConnector(cfg)
+	require.NoError(t, err)
+	connector.athena = api
+	connector.backoff = noBackoff{}
+	return connector
+}
+
+func openTestConnector(t *testing.T, connector driver.Connector) *sql.DB {
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// OpenFakeDB opens a DB configured with cfg on a fake Athena, for the tests
+// of other packages. wait blocks until the hooks were called for every
+// event so far.
+func OpenFakeDB(t *testing.T, cfg DriverConfig) (db *sql.DB, wait func()) {
+	connector := newTestConnector(t, newFakeAthena(), cfg)
+	return openTestConnector(t, connector), connector.hooks.wait
+}
+
+func openTestDB(t *testing.T, api athenaAPI) *sql.DB {
+	return openTestConnector(t, newTestConnector(t, api, DriverConfig{}))
+}
+
+func TestConn_QueryContext(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateQueued, types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	db := openTestDB(t, fake)
+
+	rows, err := db.QueryContext(context.Background(), "select")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	cnt := 0
+	for rows.Next() {
+		cnt++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 9, cnt)
+
+	require.Len(t, fake.started, 1)
+	assert.Equal(t, "test_db", aws.ToString(fake.started[0].QueryExecutionContext.Database))
+	assert.Equal(t, "s3://test-bucket/output", aws.ToString(fake.started[0].ResultConfiguration.OutputLocation))
+}
+
+func TestConn_QueryContextFailed(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateFailed)
+	db := openTestDB(t, fake)
+
+	_, err := db.QueryContext(context.Background(), "select")
+	assert.EqualError(t, err, "query failed")
+}
+
+func TestConn_QueryContextCanceled(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	db := openTestDB(t, fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := db.QueryContext(ctx, "select")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"query-1"}, fake.stopped)
+}
+
+func TestConn_ResultReuse(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestDB(t, fake)
+
+	var info ExecutionInfo
+	ctx := WithExecutionInfo(context.Background(), &info)
+	_, err := db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+	assert.Nil(t, fake.started[0].ResultReuseConfiguration)
+	assert.False(t, info.ReusedPreviousResult)
+
+	ctx = WithResultReuse(ctx, ResultReuseConfig{Enabled: true, MaxAge: 90 * time.Second})
+	_, err = db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+	byAge := fake.started[1].ResultReuseConfiguration.ResultReuseByAgeConfiguration
+	assert.True(t, byAge.Enabled)
+	assert.Equal(t, int32(2), aws.ToInt32(byAge.MaxAgeInMinutes))
+	assert.True(t, info.ReusedPreviousResult)
+	assert.Equal(t, QueryID("query-2"), info.QueryID)
+
+	ctx = WithResultReuse(ctx, ResultReuseConfig{Enabled: true, MaxAge: 30 * 24 * time.Hour})
+	_, err = db.ExecContext(ctx, "select")
+	assert.Error(t, err)
+}
+
+func TestConn_QueryOptions(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestDB(t, fake)
+
+	ctx := WithQueryOptions(context.Background(), QueryOptions{
+		Database:  "other_db",
+		WorkGroup: "etl",
+	})
+	ctx = WithQueryOptions(ctx, QueryOptions{
+		OutputLocation:     "s3://other-bucket/prefix",
+		ClientRequestToken: "token",
+		Encryption:         &types.EncryptionConfiguration{EncryptionOption: types.EncryptionOptionSseS3},
+		ResultMode:         ResultModeNoHeader,
+	})
+	rows, err := db.QueryContext(ctx, "show")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	cnt := 0
+	for rows.Next() {
+		cnt++
+	}
+	assert.Equal(t, 2, cnt)
+
+	in := fake.started[0]
+	assert.Equal(t, "other_db", aws.ToString(in.QueryExecutionContext.Database))
+	assert.Nil(t, in.QueryExecutionContext.Catalog)
+	assert.Equal(t, "etl", aws.ToString(in.WorkGroup))
+	assert.Equal(t, "s3://other-bucket/prefix", aws.ToString(in.ResultConfiguration.OutputLocation))
+	assert.Equal(t, types.EncryptionOptionSseS3, in.ResultConfiguration.EncryptionConfiguration.EncryptionOption)
+	assert.Equal(t, "token", aws.ToString(in.ClientRequestToken))
+}
+
+func TestConn_QueryOptionsTimeout(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	db := openTestDB(t, fake)
+
+	ctx := WithQueryOptions(context.Background(), QueryOptions{Timeout: 20 * time.Millisecond})
+	_, err := db.ExecContext(ctx, "select")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"query-1"}, fake.stopped)
+}
+
+func TestConn_StartQueryRetries(t *testing.T) {
+	fake := newFakeAthena()
+	fake.startErrs = []error{&types.TooManyRequestsException{}, &types.TooManyRequestsException{}}
+	db := openTestDB(t, fake)
+
+	var info ExecutionInfo
+	_, err := db.ExecContext(WithExecutionInfo(context.Background(), &info), "select")
+	require.NoError(t, err)
+
+	require.Len(t, fake.started, 3)
+	token := aws.ToString(fake.started[0].ClientRequestToken)
+	assert.NotEmpty(t, token)
+	for _, in := range fake.started {
+		assert.Equal(t, token, aws.ToString(in.ClientRequestToken))
+	}
+	assert.Len(t, fake.execs, 1)
+	assert.Equal(t, QueryID("query-1"), info.QueryID)
+
+	_, err = db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+	assert.NotEqual(t, token, aws.ToString(fake.started[3].ClientRequestToken), "each query must get its own token")
+}
+
+func TestConn_StartQueryDoesNotRetryClientErrors(t *testing.T) {
+	fake := newFakeAthena()
+	fake.startErrs = []error{&types.InvalidRequestException{Message: aws.String("bad query")}}
+	db := openTestDB(t, fake)
+
+	_, err := db.ExecContext(context.Background(), "select")
+	assert.ErrorContains(t, err, "bad query")
+	assert.Len(t, fake.started, 1)
+}
@@ -0,0 +1,149 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Connector is a driver.Connector holding the state shared by the connections
+// of a DB, such as cost accounting. It's meant to be used with `sql.OpenDB`:
+//
+//	connector, err := athena.NewConnector(cfg)
+//	db := sql.OpenDB(connector)
+//	...
+//	stats := connector.Stats()
+type Connector struct {
+	cfg     DriverConfig
+	athena  athenaAPI
+	queries *inFlightQueries
+	flights *flightGroup
+	cleaner *resultCleaner
+	costs   *costAccountant
+	tracer  trace.Tracer
+	hooks   *hookDispatcher
+	logger  *slog.Logger
+
+	backoff retry.BackoffDelayer
+}
+
+// NewConnector returns a Connector for cfg.
+func NewConnector(cfg DriverConfig) (*Connector, error) {
+	if cfg.Config == nil {
+		return nil, errors.New("AWS config is required")
+	}
+
+	if err := cfg.ResultReuse.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validateOutputLocation(cfg.OutputLocation); err != nil {
+		return nil, err
+	}
+
+	if err := validatePageSize(cfg.PageSize); err != nil {
+		return nil, err
+	}
+
+	if cfg.PollFrequency == 0 {
+		cfg.PollFrequency = 5 * time.Second
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	var flights *flightGroup
+	if cfg.DeduplicateQueries {
+		flights = newFlightGroup()
+	}
+
+	hooks := newHookDispatcher(cfg.Hooks)
+	logger := newLogger(cfg.Logger)
+
+	return &Connector{
+		cfg:     cfg,
+		athena:  athena.NewFromConfig(*cfg.Config),
+		queries: newInFlightQueries(),
+		flights: flights,
+		costs:   newCostAccountant(cfg.PricePerTB),
+		tracer:  newTracer(cfg.TracerProvider),
+		hooks:   hooks,
+		logger:  logger,
+		cleaner: newResultCleaner(s3.NewFromConfig(*cfg.Config), hooks, logger),
+	}, nil
+}
+
+// Connect implements driver.Connector.
+func (c *Connector) Connect(context.Context) (driver.Conn, error) {
+	var cleaner *resultCleaner
+	if c.cfg.CleanupResults {
+		cleaner = c.cleaner
+	}
+
+	return &conn{
+		athena:         c.cfg.Limiter.wrap(c.athena),
+		db:             c.cfg.Database,
+		catalog:        c.cfg.Catalog,
+		workGroup:      c.cfg.WorkGroup,
+		OutputLocation: c.cfg.OutputLocation,
+		pollFrequency:  c.cfg.PollFrequency,
+		pageSize:       c.cfg.PageSize,
+		progress:       c.cfg.Progress,
+		statsThreshold: c.cfg.RuntimeStatisticsThreshold,
+		resultReuse:    c.cfg.ResultReuse,
+		historyReuse:   c.cfg.HistoryReuse,
+		startAttempts:  c.cfg.StartQueryAttempts,
+		backoff:        c.backoff,
+		limiter:        c.cfg.Limiter,
+
+		maxBytesScanned:    c.cfg.MaxBytesScanned,
+		checkScanEstimates: c.cfg.CheckScanEstimates,
+
+		queries:         c.queries,
+		shutdownTimeout: c.cfg.ShutdownTimeout,
+		flights:         c.flights,
+		cache:           c.cfg.ResultCache,
+		cleaner:         cleaner,
+
+		costs:  c.costs,
+		tracer: c.tracer,
+		hooks:  c.hooks,
+		logger: c.logger,
+	}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *Connector) Driver() driver.Driver {
+	return &Driver{cfg: &c.cfg}
+}
+
+// Stats returns the usage and estimated cost of the queries run through the
+// connector so far.
+func (c *Connector) Stats() CostStats {
+	return c.costs.snapshot()
+}
+
+// Stats returns the usage and estimated cost of the queries run through db
+// so far, which must be an Athena database, e.g. one returned by Open. It's
+// Connector.Stats for DBs whose Connector isn't at hand.
+func Stats(ctx context.Context, db *sql.DB) (CostStats, error) {
+	var stats CostStats
+	err := withConn(ctx, db, func(cn *conn) error {
+		stats = cn.costs.snapshot()
+		return nil
+	})
+	return stats, err
+}
+
+var _ driver.Connector = (*Connector)(nil)
+var _ io.Closer = (*Connector)(nil)
+var _ driver.DriverContext = (*Driver)(nil)
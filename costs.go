@@ -0,0 +1,141 @@
+package athena
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+const (
+	defaultPricePerTB = 5.0
+	bytesPerTB        = 1 << 40
+	bytesPerMB        = 1 << 20
+
+	// minBilledBytes is the minimum Athena charges for a query scanning data.
+	minBilledBytes = 10 * bytesPerMB
+)
+
+// CostTotals aggregates the usage of a set of queries.
+type CostTotals struct {
+	Queries      int64
+	BytesScanned int64
+	EngineTime   time.Duration
+
+	// EstimatedCost is in US dollars, based on DriverConfig.PricePerTB.
+	EstimatedCost float64
+}
+
+func (t *CostTotals) add(o CostTotals) {
+	t.Queries += o.Queries
+	t.BytesScanned += o.BytesScanned
+	t.EngineTime += o.EngineTime
+	t.EstimatedCost += o.EstimatedCost
+}
+
+// LabeledCosts are the totals of the queries run with a given set of labels.
+// See WithLabels.
+type LabeledCosts struct {
+	Labels map[string]string
+	CostTotals
+}
+
+// CostStats is a snapshot of the usage of the queries run through a Connector.
+type CostStats struct {
+	Total CostTotals
+
+	// ByLabels has an entry per distinct set of labels, including the empty
+	// set for queries run without labels.
+	ByLabels []LabeledCosts
+}
+
+// costAccountant aggregates the costs of queries.
+type costAccountant struct {
+	pricePerTB float64
+
+	mu       sync.Mutex
+	total    CostTotals
+	byLabels map[string]*LabeledCosts
+}
+
+func newCostAccountant(pricePerTB float64) *costAccountant {
+	if pricePerTB <= 0 {
+		pricePerTB = defaultPricePerTB
+	}
+	return &costAccountant{
+		pricePerTB: pricePerTB,
+		byLabels:   map[string]*LabeledCosts{},
+	}
+}
+
+// record accounts for a query execution run with labels.
+func (a *costAccountant) record(labels map[string]string, qe *types.QueryExecution) {
+	if a == nil || qe == nil {
+		return
+	}
+
+	totals := CostTotals{Queries: 1}
+	if stats := qe.Statistics; stats != nil {
+		totals.BytesScanned = aws.ToInt64(stats.DataScannedInBytes)
+		totals.EngineTime = millis(stats.EngineExecutionTimeInMillis)
+	}
+	// Athena doesn't charge for failed queries.
+	if qe.Status == nil || qe.Status.State != types.QueryExecutionStateFailed {
+		totals.EstimatedCost = a.estimateCost(totals.BytesScanned)
+	}
+
+	key := labelsKeyOf(labels)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.total.add(totals)
+	entry, ok := a.byLabels[key]
+	if !ok {
+		entry = &LabeledCosts{Labels: copyLabels(labels)}
+		a.byLabels[key] = entry
+	}
+	entry.add(totals)
+}
+
+// estimateCost returns the price of scanning the given number of bytes.
+// Athena rounds data scanned up to the megabyte, with a 10MB minimum.
+func (a *costAccountant) estimateCost(scanned int64) float64 {
+	if scanned <= 0 {
+		return 0
+	}
+
+	billed := max(scanned, minBilledBytes)
+	billed = (billed + bytesPerMB - 1) / bytesPerMB * bytesPerMB
+	return float64(billed) / bytesPerTB * a.pricePerTB
+}
+
+func (a *costAccountant) snapshot() CostStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := CostStats{Total: a.total}
+	keys := make([]string, 0, len(a.byLabels))
+	for key := range a.byLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := *a.byLabels[key]
+		entry.Labels = copyLabels(entry.Labels)
+		stats.ByLabels = append(stats.ByLabels, entry)
+	}
+
+	return stats
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	cp := make(map[string]string, len(labels))
+	for k, v := range labels {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,79 @@
+package athena
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnector_Stats(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	connector := newTestConnector(t, fake, DriverConfig{PricePerTB: 5})
+	db := openTestConnector(t, connector)
+
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+
+	ctx = WithLabels(ctx, map[string]string{"feature": "dashboard"})
+	for i := 0; i < 2; i++ {
+		_, err = db.ExecContext(WithLabels(ctx, map[string]string{"team": "growth"}), "select")
+		require.NoError(t, err)
+	}
+
+	// Each query scans 2KB, billed as 10MB.
+	queryCost := 5 * float64(10<<20) / (1 << 40)
+
+	stats := connector.Stats()
+	assert.Equal(t, int64(3), stats.Total.Queries)
+	assert.Equal(t, int64(3*2048), stats.Total.BytesScanned)
+	assert.InDelta(t, 3*queryCost, stats.Total.EstimatedCost, 1e-12)
+
+	require.Len(t, stats.ByLabels, 2)
+	assert.Empty(t, stats.ByLabels[0].Labels)
+	assert.Equal(t, int64(1), stats.ByLabels[0].Queries)
+	assert.Equal(t, map[string]string{"feature": "dashboard", "team": "growth"}, stats.ByLabels[1].Labels)
+	assert.Equal(t, int64(2), stats.ByLabels[1].Queries)
+	assert.InDelta(t, 2*queryCost, stats.ByLabels[1].EstimatedCost, 1e-12)
+}
+
+func TestStats(t *testing.T) {
+	connector := newTestConnector(t, newFakeAthena(), DriverConfig{})
+	db := openTestConnector(t, connector)
+
+	_, err := db.ExecContext(WithLabels(context.Background(), map[string]string{"team": "growth"}), "select")
+	require.NoError(t, err)
+
+	stats, err := Stats(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, connector.Stats(), stats)
+	assert.Equal(t, int64(1), stats.Total.Queries)
+}
+
+func TestCostAccountant_LabelsKey(t *testing.T) {
+	assert.NotEqual(t,
+		labelsKeyOf(map[string]string{"a": "b,c=d"}),
+		labelsKeyOf(map[string]string{"a": "b", "c": "d"}))
+
+	a := newCostAccountant(0)
+	qe := &types.QueryExecution{}
+	a.record(map[string]string{"a": "b,c=d"}, qe)
+	a.record(map[string]string{"a": "b", "c": "d"}, qe)
+
+	stats := a.snapshot()
+	require.Len(t, stats.ByLabels, 2)
+	for _, entry := range stats.ByLabels {
+		assert.Equal(t, int64(1), entry.Queries, entry.Labels)
+	}
+}
+
+func TestCostAccountant_EstimateCost(t *testing.T) {
+	a := newCostAccountant(0)
+	assert.Equal(t, 0.0, a.estimateCost(0))
+	assert.Equal(t, a.estimateCost(1), a.estimateCost(10<<20))
+	assert.Equal(t, a.estimateCost(11<<20), a.estimateCost(10<<20+1))
+	assert.InDelta(t, 5.0, a.estimateCost(1<<40), 1e-12)
+}
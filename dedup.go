@@ -0,0 +1,118 @@
+package athena
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// flightGroup shares the execution of identical queries run concurrently.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an execution shared by the queries waiting on it.
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	ex      execution
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: map[string]*flight{}}
+}
+
+// do returns the outcome of execute, or of the execution of an identical
+// query already in flight, as identified by key.
+//
+// The execution runs regardless of the context of the caller starting it,
+// so that it goes on for the other callers. It's canceled only once every
+// caller gave up on it.
+func (g *flightGroup) do(ctx context.Context, key string, execute func(context.Context) execution) execution {
+	g.mu.Lock()
+	f, shared := g.flights[key]
+	if !shared {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+
+		go func() {
+			defer close(f.done)
+			defer cancel()
+			f.ex = execute(flightCtx)
+			g.forget(key, f)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		g.mu.Lock()
+		alone := f.waiters == 1
+		g.mu.Unlock()
+
+		ex := f.ex
+		ex.shared = shared
+		ex.owned = ex.owned && alone
+		return ex
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody waits on the query anymore, which stops it.
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.mu.Unlock()
+		return execution{err: ctx.Err()}
+	}
+}
+
+// forget makes later queries with key start an execution of their own.
+func (g *flightGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// flightKey identifies the queries that may share an execution: the same
+// SQL, run with the same options and labels.
+func (c *conn) flightKey(ctx context.Context, query string, opts QueryOptions) string {
+	reuse := c.resultReuse
+	if override, ok := resultReuseFromContext(ctx); ok {
+		reuse = override
+	}
+	location, err := outputLocation(ctx, opts, time.Now())
+	if err != nil {
+		// The query fails to start either way.
+		location = opts.OutputLocation
+	}
+
+	fields := []string{
+		query,
+		opts.Database,
+		opts.Catalog,
+		opts.WorkGroup,
+		location,
+		labelsKeyOf(labelsFromContext(ctx)),
+		opts.ClientRequestToken,
+		strconv.FormatInt(opts.MaxBytesScanned, 10),
+		strconv.FormatBool(reuse.Enabled),
+		reuse.MaxAge.String(),
+	}
+	if enc := opts.Encryption; enc != nil {
+		fields = append(fields, string(enc.EncryptionOption), aws.ToString(enc.KmsKey))
+	}
+	return strings.Join(fields, "\x00")
+}
@@ -0,0 +1,193 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waiters returns the number of queries waiting on the executions in flight.
+func waiters(connector *Connector) int {
+	connector.flights.mu.Lock()
+	defer connector.flights.mu.Unlock()
+
+	n := 0
+	for _, f := range connector.flights.flights {
+		n += f.waiters
+	}
+	return n
+}
+
+func TestConn_DeduplicateQueries(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	fake.hold = make(chan struct{})
+	hooks := &recordingHooks{}
+	connector := newTestConnector(t, fake, DriverConfig{DeduplicateQueries: true, Hooks: hooks})
+	db := openTestConnector(t, connector)
+
+	type result struct {
+		rows int
+		info ExecutionInfo
+		err  error
+	}
+	results := make(chan result, 3)
+	run := func(query string) {
+		var res result
+		rows, err := db.QueryContext(WithExecutionInfo(context.Background(), &res.info), query)
+		if err == nil {
+			for rows.Next() {
+				res.rows++
+			}
+			err = rows.Close()
+		}
+		res.err = err
+		results <- res
+	}
+
+	go run("select")
+	require.Eventually(t, func() bool { return waiters(connector) == 1 }, time.Second, time.Millisecond)
+	go run("select")
+	go run("show")
+	require.Eventually(t, func() bool { return waiters(connector) == 3 }, time.Second, time.Millisecond)
+	close(fake.hold)
+
+	shared := 0
+	rows := map[int]int{}
+	for i := 0; i < 3; i++ {
+		res := <-results
+		require.NoError(t, res.err)
+		rows[res.rows]++
+		if res.info.Shared {
+			shared++
+		}
+	}
+	assert.Equal(t, map[int]int{9: 2, 1: 1}, rows)
+	assert.Equal(t, 1, shared)
+	assert.Len(t, fake.started, 2)
+
+	// The shared execution finishes once.
+	connector.hooks.wait()
+	assert.ElementsMatch(t, []string{"finish query-1 <nil> 2048", "finish query-2 <nil> 2048"}, hooks.finished())
+
+	// Later queries run on their own.
+	_, err := db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+	assert.Len(t, fake.started, 3)
+}
+
+func TestConn_DeduplicateQueriesAbandoned(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	fake.hold = make(chan struct{})
+	hooks := &recordingHooks{}
+	connector := newTestConnector(t, fake, DriverConfig{DeduplicateQueries: true, Hooks: hooks})
+	db := openTestConnector(t, connector)
+
+	errs := make(chan error, 2)
+	run := func(ctx context.Context) {
+		_, err := db.ExecContext(ctx, "select")
+		errs <- err
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	defer cancelFirst()
+	go run(firstCtx)
+	require.Eventually(t, func() bool { return waiters(connector) == 1 }, time.Second, time.Millisecond)
+	secondCtx, cancelSecond := context.WithCancel(context.Background())
+	defer cancelSecond()
+	go run(secondCtx)
+	require.Eventually(t, func() bool { return waiters(connector) == 2 }, time.Second, time.Millisecond)
+
+	// The query keeps running for the second caller.
+	cancelFirst()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	close(fake.hold)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, fake.startedCount())
+	assert.Empty(t, stoppedQueries(fake))
+
+	cancelSecond()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	require.Eventually(t, func() bool { return len(stoppedQueries(fake)) == 1 }, time.Second, time.Millisecond)
+
+	// The execution is reported once stopped, though nobody waits on it.
+	require.Eventually(t, func() bool {
+		connector.hooks.wait()
+		return len(hooks.finished()) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestConn_DeduplicateQueriesKey(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	fake.hold = make(chan struct{})
+	connector := newTestConnector(t, fake, DriverConfig{
+		DeduplicateQueries: true,
+		OutputLocation:     "s3://test-bucket/{label:team}",
+	})
+	db := openTestConnector(t, connector)
+
+	errs := make(chan error, 3)
+	run := func(team string) {
+		_, err := db.ExecContext(WithLabels(context.Background(), map[string]string{"team": team}), "select")
+		errs <- err
+	}
+
+	go run("a")
+	go run("b")
+	go run("a")
+	require.Eventually(t, func() bool { return waiters(connector) == 3 }, time.Second, time.Millisecond)
+	close(fake.hold)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	// Queries with other labels run on their own, in their own location.
+	var locations []string
+	for _, in := range fake.started {
+		locations = append(locations, aws.ToString(in.ResultConfiguration.OutputLocation))
+	}
+	assert.ElementsMatch(t, []string{"s3://test-bucket/a", "s3://test-bucket/b"}, locations)
+}
+
+func stoppedQueries(fake *fakeAthena) []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]string(nil), fake.stopped...)
+}
+
+func TestConn_DeduplicateQueriesClosedConn(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	fake.hold = make(chan struct{})
+	connector := newTestConnector(t, fake, DriverConfig{DeduplicateQueries: true})
+	db := openTestConnector(t, connector)
+	// Connections are closed as soon as they're released.
+	db.SetMaxIdleConns(0)
+
+	errs := make(chan error, 2)
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	defer cancelFirst()
+	go func() {
+		_, err := db.ExecContext(firstCtx, "select")
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return waiters(connector) == 1 }, time.Second, time.Millisecond)
+	go func() {
+		_, err := db.ExecContext(context.Background(), "select")
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return waiters(connector) == 2 }, time.Second, time.Millisecond)
+
+	// Closing the connection of the first query doesn't stop the execution
+	// the second one still waits on.
+	cancelFirst()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	require.Eventually(t, func() bool { return db.Stats().OpenConnections == 1 }, time.Second, time.Millisecond)
+	close(fake.hold)
+	require.NoError(t, <-errs)
+	assert.Empty(t, stoppedQueries(fake))
+	assert.Zero(t, inFlight(connector))
+}
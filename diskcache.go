@@ -0,0 +1,126 @@
+package athena
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiskCache is a ResultCache keeping results in files of a directory,
+// evicting the oldest ones to stay under its size limit. Results survive
+// restarts, and the directory may be shared by the processes of a host.
+type DiskCache struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+
+	mu sync.Mutex
+}
+
+// NewDiskCache returns a DiskCache storing results in dir, created if needed,
+// keeping them up to ttl, and up to maxBytes of them in total.
+func NewDiskCache(dir string, ttl time.Duration, maxBytes int64) (*DiskCache, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir, ttl: ttl, maxBytes: maxBytes}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get implements ResultCache. Unreadable entries are treated as missing.
+func (c *DiskCache) Get(key string) (*CachedResult, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var result CachedResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+	if time.Since(result.CachedAt) > c.ttl {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+
+	// Keep the entries read recently from being evicted first.
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now)
+	return &result, true
+}
+
+// Put implements ResultCache. The cache being best effort, failures to
+// write the entry are ignored.
+func (c *DiskCache) Put(key string, result *CachedResult) {
+	data, err := json.Marshal(result)
+	if err != nil || int64(len(data)) > c.maxBytes {
+		return
+	}
+
+	// Write to a temporary file first, so that readers never see a partial
+	// entry.
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	c.evict()
+}
+
+// evict removes the least recently used entries until the cache fits in
+// maxBytes.
+func (c *DiskCache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return
+	}
+
+	var entries []os.FileInfo
+	var size int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, info)
+		size += info.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, entry := range entries {
+		if size <= c.maxBytes {
+			return
+		}
+		if os.Remove(filepath.Join(c.dir, entry.Name())) == nil {
+			size -= entry.Size()
+		}
+	}
+}
+
+var _ ResultCache = (*DiskCache)(nil)
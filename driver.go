@@ -6,18 +6,14 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/athena"
-)
-
-var (
-	openFromSessionMutex sync.Mutex
-	openFromSessionCount int
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Driver is a sql.Driver. It's intended for db/sql.Open().
@@ -51,11 +47,28 @@ func init() {
 // "s3://bucket/and/so/forth". In the AWS UI, this defaults to
 // "s3://aws-athena-query-results-<ACCOUNTID>-<REGION>", but the driver requires it.
 //
+// - `catalog` (optional)
+// The data catalog the database belongs to. Athena defaults to "AwsDataCatalog".
+//
+// - `workgroup` (optional)
+// The Athena workgroup queries run in. Athena defaults to "primary".
+//
 // - `poll_frequency` (optional)
 // Athena's API requires polling to retrieve query results. This is the frequency at
 // which the driver will poll for results. It should be a time/Duration.String().
 // A completely arbitrary default of "5s" was chosen.
 //
+// - `result_reuse_max_age` (optional)
+// Enables Athena's query result reuse, allowing results of an identical query
+// up to this old to be returned instead of running the query again.
+// It should be a time/Duration.String(), e.g. "60m".
+//
+// - `max_bytes_scanned` (optional)
+// Stops queries once they scanned more than this many bytes.
+//
+// - `page_size` (optional)
+// The number of rows fetched per page of results, up to 1000.
+//
 // - `region` (optional)
 // Override AWS region. Useful if it is not set with environment variable.
 //
@@ -63,26 +76,28 @@ func init() {
 // For more advanced AWS credentials/session/config management, please supply
 // a custom AWS session directly via `athena.Open()`.
 func (d *Driver) Open(connStr string) (driver.Conn, error) {
-	cfg := d.cfg
-	if cfg == nil {
-		var err error
-		// TODO: Implement DriverContext to get proper access to context
-		cfg, err = configFromConnectionString(context.TODO(), connStr)
-		if err != nil {
-			return nil, err
-		}
+	connector, err := d.OpenConnector(connStr)
+	if err != nil {
+		return nil, err
 	}
 
-	if cfg.PollFrequency == 0 {
-		cfg.PollFrequency = 5 * time.Second
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext, so that the connections of
+// a DB opened with `db/sql.Open("athena", "<params>")` share a Connector.
+// connStr takes the parameters documented on Open.
+func (d *Driver) OpenConnector(connStr string) (driver.Connector, error) {
+	if d.cfg != nil {
+		return NewConnector(*d.cfg)
 	}
 
-	return &conn{
-		athena:         athena.NewFromConfig(*cfg.Config),
-		db:             cfg.Database,
-		OutputLocation: cfg.OutputLocation,
-		pollFrequency:  cfg.PollFrequency,
-	}, nil
+	cfg, err := configFromConnectionString(context.Background(), connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConnector(*cfg)
 }
 
 // Open is a more robust version of `db.Open`, as it accepts a raw aws.Session.
@@ -101,24 +116,130 @@ func Open(cfg DriverConfig) (*sql.DB, error) {
 		return nil, errors.New("AWS config is required")
 	}
 
-	// This hack was copied from jackc/pgx. Sorry :(
-	// https://github.com/jackc/pgx/blob/70a284f4f33a9cc28fd1223f6b83fb00deecfe33/stdlib/sql.go#L130-L136
-	openFromSessionMutex.Lock()
-	openFromSessionCount++
-	name := fmt.Sprintf("athena-%d", openFromSessionCount)
-	openFromSessionMutex.Unlock()
+	connector, err := NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	sql.Register(name, &Driver{&cfg})
-	return sql.Open(name, "")
+	return sql.OpenDB(connector), nil
 }
 
 // Config is the input to Open().
 type DriverConfig struct {
-	Config         *aws.Config
-	Database       string
+	Config   *aws.Config
+	Database string
+
+	// OutputLocation is the S3 URL Athena writes query results to. It may
+	// be a template, expanded for every query, with these placeholders:
+	//
+	//   - {workgroup}, {database} and {catalog}: the query's options, or
+	//     Athena's defaults, e.g. "primary" for the workgroup.
+	//   - {date}, {year}, {month}, {day} and {hour}: the time the query
+	//     starts, in UTC, {date} being formatted as "2006-01-02".
+	//   - {label:<name>}: the value of the label <name>, see WithLabels.
+	//   - {label}: every label, as "<name>=<value>" path segments.
+	//
+	// Missing values expand to "none", and values are escaped so that they
+	// can't add path segments. For example:
+	//
+	//	s3://bucket/{workgroup}/{date}/{label:team}/
 	OutputLocation string
 
+	// Catalog and WorkGroup are optional. Athena's defaults apply if unset.
+	Catalog   string
+	WorkGroup string
+
 	PollFrequency time.Duration
+
+	// PageSize is the number of rows fetched per call to GetQueryResults, up
+	// to 1000. Smaller pages return the first rows sooner, e.g. to preview
+	// results. Zero uses Athena's default of 1000. It can be overridden per
+	// query with QueryOptions.
+	//
+	// Whatever the page size, no more rows are fetched than the LIMIT clause
+	// ending a query, if any, asks for. The clause is found in the text of the
+	// query, so queries containing "--" or "/*" anywhere, even in a string
+	// literal, are fetched in full pages, as the clause may be commented out.
+	PageSize int
+
+	// Progress, if set, receives the progress of queries on every poll.
+	// It can be overridden per query with WithProgress.
+	Progress ProgressFunc
+
+	// RuntimeStatisticsThreshold, if set, attaches the runtime statistics of
+	// queries taking at least this long to their ExecutionInfo, at the cost
+	// of an extra API call. See WithExecutionInfo and Hooks.OnFinish.
+	RuntimeStatisticsThreshold time.Duration
+
+	// StartQueryAttempts is the number of times the driver tries to start a
+	// query when StartQueryExecution fails with a retryable error, on top of
+	// the retries of the AWS SDK. Defaults to 3.
+	StartQueryAttempts int
+
+	// Limiter, if set, bounds the queries run and API calls made by the
+	// driver. It may be shared by several DBs. See NewLimiter.
+	Limiter *Limiter
+
+	// DeduplicateQueries makes identical queries run concurrently through the
+	// connector share a single execution: the same SQL, with the same
+	// database, catalog, workgroup, labels, output location and other
+	// options. Each query still reads the results on its own. The execution
+	// is stopped only once every query sharing it gave up. It runs with the
+	// priority and progress callback of the query that started it, and is
+	// reported to the Hooks once. See ExecutionInfo.Shared.
+	DeduplicateQueries bool
+
+	// ResultCache, if set, caches the results of SELECT queries, which are
+	// then returned without running the query again. Results can be read
+	// from the cache across DBs sharing it. See ExecutionInfo.Cached and
+	// QueryOptions.NoCache.
+	ResultCache ResultCache
+
+	// CleanupResults deletes the result and metadata objects of queries from
+	// S3 once their rows are closed, or once an Exec is done. Results of
+	// queries shared with other callers aren't deleted, and deleted results
+	// can't be reused by other clients, e.g. through HistoryReuse.
+	// Deletions are batched. Failures are logged and reported to Hooks
+	// implementing CleanupHooks.
+	CleanupResults bool
+
+	// ShutdownTimeout bounds the time `db.Close` and `conn.Close` spend
+	// stopping the queries in flight. Defaults to 30s. See Connector.Shutdown.
+	ShutdownTimeout time.Duration
+
+	// ResultReuse lets Athena reuse the results of identical recent queries.
+	// It can be overridden per query with WithResultReuse.
+	ResultReuse ResultReuseConfig
+
+	// HistoryReuse, if set, reuses the results of recent executions of the
+	// same query found in the workgroup's history. See HistoryReuseConfig.
+	HistoryReuse HistoryReuseConfig
+
+	// MaxBytesScanned stops queries once they scanned more bytes than this,
+	// failing them with a *ScanBudgetError. It can be overridden per query
+	// with QueryOptions. Zero means no limit.
+	MaxBytesScanned int64
+
+	// CheckScanEstimates makes the driver run `EXPLAIN` before each query with
+	// a MaxBytesScanned limit, rejecting it without running it if Athena
+	// estimates it'll scan more than the limit.
+	CheckScanEstimates bool
+
+	// PricePerTB is the price in US dollars Athena charges per terabyte
+	// scanned, used to estimate the cost of queries in Connector.Stats.
+	// Defaults to 5.
+	PricePerTB float64
+
+	// TracerProvider, if set, is used to trace queries with OpenTelemetry:
+	// their submission, the polling of their state and each page of results.
+	TracerProvider trace.TracerProvider
+
+	// Hooks, if set, are notified of the lifecycle of queries.
+	Hooks Hooks
+
+	// Logger, if set, receives structured logs about the lifecycle of
+	// queries. Their string literals are redacted from the logs.
+	Logger *slog.Logger
 }
 
 func configFromConnectionString(ctx context.Context, connStr string) (*DriverConfig, error) {
@@ -140,6 +261,8 @@ func configFromConnectionString(ctx context.Context, connStr string) (*DriverCon
 
 	cfg.Database = args.Get("db")
 	cfg.OutputLocation = args.Get("output_location")
+	cfg.Catalog = args.Get("catalog")
+	cfg.WorkGroup = args.Get("workgroup")
 
 	frequencyStr := args.Get("poll_frequency")
 	if frequencyStr != "" {
@@ -149,5 +272,30 @@ func configFromConnectionString(ctx context.Context, connStr string) (*DriverCon
 		}
 	}
 
+	if maxBytesStr := args.Get("max_bytes_scanned"); maxBytesStr != "" {
+		cfg.MaxBytesScanned, err = strconv.ParseInt(maxBytesStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_bytes_scanned parameter: %s", maxBytesStr)
+		}
+	}
+
+	if pageSizeStr := args.Get("page_size"); pageSizeStr != "" {
+		cfg.PageSize, err = strconv.Atoi(pageSizeStr)
+		if err != nil || validatePageSize(cfg.PageSize) != nil {
+			return nil, fmt.Errorf("invalid page_size parameter: %s", pageSizeStr)
+		}
+	}
+
+	if maxAgeStr := args.Get("result_reuse_max_age"); maxAgeStr != "" {
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result_reuse_max_age parameter: %s", maxAgeStr)
+		}
+		cfg.ResultReuse = ResultReuseConfig{Enabled: true, MaxAge: maxAge}
+		if err := cfg.ResultReuse.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,322 @@
+package athena
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/compress"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// ExportFormat is a format Export writes query results in.
+type ExportFormat int
+
+const (
+	// ExportCSV writes results as CSV, with a header row of column names.
+	// NULLs are written as empty fields.
+	ExportCSV ExportFormat = iota
+
+	// ExportNDJSON writes results as newline-delimited JSON, one object per
+	// row keyed by column name, with the columns in order. Numbers and
+	// booleans are written as JSON numbers and booleans, arrays, maps and
+	// rows as JSON arrays and objects, and NULLs as null.
+	ExportNDJSON
+
+	// ExportParquet writes results as a Parquet file, with a schema derived
+	// from the Athena column types.
+	ExportParquet
+)
+
+func (f ExportFormat) String() string {
+	switch f {
+	case ExportCSV:
+		return "csv"
+	case ExportNDJSON:
+		return "ndjson"
+	case ExportParquet:
+		return "parquet"
+	default:
+		return "ExportFormat(" + strconv.Itoa(int(f)) + ")"
+	}
+}
+
+// parquetBatchRows is the number of rows written to each Parquet row group.
+const parquetBatchRows = 64 * 1024
+
+// Export runs query on db, which must be an Athena database, and streams its
+// results to w in the given format. Rows are written as they're read, so
+// results don't need to fit in memory, except for the current Parquet row
+// group.
+//
+// Values are exported from the text Athena returns for them, without
+// converting them to Go values first, so decimals keep their precision. CSV
+// fields are that text. In NDJSON, numbers are written as such, except for
+// NaN and infinities, arrays as arrays of strings and maps and rows as
+// objects of strings, and other values as strings. Parquet files have the
+// schema of the records of QueryArrow.
+func Export(ctx context.Context, db *sql.DB, query string, format ExportFormat, w io.Writer) (err error) {
+	sqlConn, results, err := queryPages(ctx, db, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := results.Close(); err == nil {
+			err = cerr
+		}
+		sqlConn.Close()
+	}()
+
+	columns := results.columnInfo()
+	var enc exportEncoder
+	switch format {
+	case ExportCSV:
+		enc, err = newCSVEncoder(w, columns)
+	case ExportNDJSON:
+		enc = newNDJSONEncoder(w, columns)
+	case ExportParquet:
+		enc, err = newParquetEncoder(w, columns)
+	default:
+		err = fmt.Errorf("athena: unknown export format %s", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		page, err := results.nextPage()
+		if err == io.EOF {
+			return enc.close()
+		}
+		if err != nil {
+			enc.abort()
+			return err
+		}
+		for _, row := range page {
+			if err := enc.write(row); err != nil {
+				enc.abort()
+				return err
+			}
+		}
+	}
+}
+
+// exportEncoder writes rows in an export format.
+type exportEncoder interface {
+	// write writes a row of results, as returned by Athena.
+	write(row types.Row) error
+
+	// close flushes the rows written.
+	close() error
+
+	// abort releases the encoder's resources after an error.
+	abort()
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, columns []types.ColumnInfo) (*csvEncoder, error) {
+	e := &csvEncoder{
+		w:      csv.NewWriter(w),
+		record: make([]string, len(columns)),
+	}
+	for i, col := range columns {
+		e.record[i] = aws.ToString(col.Name)
+	}
+	if err := e.w.Write(e.record); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvEncoder) write(row types.Row) error {
+	for i, datum := range row.Data {
+		e.record[i] = aws.ToString(datum.VarCharValue)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) abort() {
+	e.w.Flush()
+}
+
+type ndjsonEncoder struct {
+	w       *bufio.Writer
+	columns []types.ColumnInfo
+	keys    [][]byte
+	buf     []byte
+}
+
+func newNDJSONEncoder(w io.Writer, columns []types.ColumnInfo) *ndjsonEncoder {
+	e := &ndjsonEncoder{
+		w:       bufio.NewWriter(w),
+		columns: columns,
+		keys:    make([][]byte, len(columns)),
+	}
+	for i, col := range columns {
+		e.keys[i] = appendJSONString(nil, aws.ToString(col.Name))
+	}
+	return e
+}
+
+func (e *ndjsonEncoder) write(row types.Row) error {
+	buf := append(e.buf[:0], '{')
+	for i, datum := range row.Data {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, e.keys[i]...)
+		buf = append(buf, ':')
+
+		var err error
+		buf, err = appendJSONValue(buf, aws.ToString(e.columns[i].Type), datum.VarCharValue)
+		if err != nil {
+			return fmt.Errorf("athena: column %q: %w", aws.ToString(e.columns[i].Name), err)
+		}
+	}
+	buf = append(buf, '}', '\n')
+	e.buf = buf
+
+	_, err := e.w.Write(buf)
+	return err
+}
+
+func (e *ndjsonEncoder) close() error {
+	return e.w.Flush()
+}
+
+func (e *ndjsonEncoder) abort() {
+	e.w.Flush()
+}
+
+// appendJSONValue appends the JSON of a value of the Athena type athenaType
+// from the text Athena returns for it.
+func appendJSONValue(buf []byte, athenaType string, text *string) ([]byte, error) {
+	if text == nil {
+		return append(buf, "null"...), nil
+	}
+
+	s := *text
+	switch athenaType {
+	case "tinyint", "smallint", "integer", "bigint", "decimal", "boolean":
+		return append(buf, s...), nil
+	case "float", "real", "double":
+		// JSON has no representation of NaN and infinities, so they're
+		// written as the strings Athena uses for them.
+		if s == "NaN" || s == "Infinity" || s == "-Infinity" {
+			return appendJSONString(buf, s), nil
+		}
+		return append(buf, s...), nil
+	case "array":
+		elems, err := parseArray(s)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, '[')
+		for i, elem := range elems {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONText(buf, elem)
+		}
+		return append(buf, ']'), nil
+	case "map", "row":
+		entries, err := parseMap(s)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, '{')
+		for i, entry := range entries {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, entry.key)
+			buf = append(buf, ':')
+			buf = appendJSONText(buf, entry.value)
+		}
+		return append(buf, '}'), nil
+	default:
+		return appendJSONString(buf, s), nil
+	}
+}
+
+// appendJSONText appends text as a JSON string, or null if it's nil.
+func appendJSONText(buf []byte, text *string) []byte {
+	if text == nil {
+		return append(buf, "null"...)
+	}
+	return appendJSONString(buf, *text)
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	// Marshalling a string can't fail.
+	b, _ := json.Marshal(s)
+	return append(buf, b...)
+}
+
+type parquetEncoder struct {
+	w *pqarrow.FileWriter
+	b *recordBuilder
+}
+
+func newParquetEncoder(w io.Writer, columns []types.ColumnInfo) (*parquetEncoder, error) {
+	schema := recordSchema(columns)
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	// Hide any Close method of w, which pqarrow would otherwise call.
+	fw, err := pqarrow.NewFileWriter(schema, struct{ io.Writer }{w}, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, err
+	}
+	return &parquetEncoder{
+		w: fw,
+		b: newRecordBuilder(memory.DefaultAllocator, schema),
+	}, nil
+}
+
+func (e *parquetEncoder) write(row types.Row) error {
+	if err := e.b.appendRow(row); err != nil {
+		return err
+	}
+	if e.b.rows < parquetBatchRows {
+		return nil
+	}
+	return e.flush()
+}
+func (e *parquetEncoder) flush() error {
+	rec := e.b.newRecord()
+	defer rec.Release()
+	return e.w.Write(rec)
+}
+
+func (e *parquetEncoder) close() error {
+	defer e.b.release()
+	if e.b.rows > 0 {
+		if err := e.flush(); err != nil {
+			e.w.Close()
+			return err
+		}
+	}
+	return e.w.Close()
+}
+
+func (e *parquetEncoder) abort() {
+	e.b.release()
+	e.w.Close()
+}
@@ -0,0 +1,164 @@
+package athena
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/pqarrow"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	queryToResultsGenMap["typed"] = dummyTypedResponse
+}
+
+// dummyTypedResponse returns results with columns of several types, and a
+// row of NULLs.
+func dummyTypedResponse(_ string) (*athena.GetQueryResultsOutput, error) {
+	column := func(name, athenaType string) types.ColumnInfo {
+		col := genColumnInfo(name)
+		col.Type = aws.String(athenaType)
+		return col
+	}
+	row := func(values ...*string) types.Row {
+		var row types.Row
+		for _, v := range values {
+			row.Data = append(row.Data, types.Datum{VarCharValue: v})
+		}
+		return row
+	}
+
+	columns := []types.ColumnInfo{
+		column("id", "bigint"),
+		column("name", "varchar"),
+		column("score", "double"),
+		column("active", "boolean"),
+		column("day", "date"),
+		column("at", "timestamp"),
+	}
+	return &athena.GetQueryResultsOutput{
+		ResultSet: &types.ResultSet{
+			ResultSetMetadata: &types.ResultSetMetadata{ColumnInfo: columns},
+			Rows: []types.Row{
+				row(aws.String("id"), aws.String("name"), aws.String("score"), aws.String("active"), aws.String("day"), aws.String("at")),
+				row(aws.String("1"), aws.String(`a "quoted", name`), aws.String("1.5"), aws.String("true"), aws.String("2024-03-01"), aws.String("2024-03-01 12:30:00.250")),
+				row(aws.String("2"), nil, nil, nil, nil, nil),
+			},
+		},
+	}, nil
+}
+
+func TestExport_CSV(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(context.Background(), db, "typed", ExportCSV, &buf))
+	assert.Equal(t, strings.Join([]string{
+		"id,name,score,active,day,at",
+		`1,"a ""quoted"", name",1.5,true,2024-03-01,2024-03-01 12:30:00.250`,
+		"2,,,,,",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestExport_NDJSON(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(context.Background(), db, "typed", ExportNDJSON, &buf))
+	assert.Equal(t, strings.Join([]string{
+		`{"id":1,"name":"a \"quoted\", name","score":1.5,"active":true,"day":"2024-03-01","at":"2024-03-01 12:30:00.250"}`,
+		`{"id":2,"name":null,"score":null,"active":null,"day":null,"at":null}`,
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestExport_Parquet(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(context.Background(), db, "typed", ExportParquet, &buf))
+
+	pf, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer pf.Close()
+	reader, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	table, err := reader.ReadTable(context.Background())
+	require.NoError(t, err)
+	defer table.Release()
+
+	require.EqualValues(t, 2, table.NumRows())
+	schema := table.Schema()
+	assert.Equal(t, arrow.PrimitiveTypes.Int64, schema.Field(0).Type)
+	assert.Equal(t, arrow.BinaryTypes.String, schema.Field(1).Type)
+	assert.Equal(t, arrow.PrimitiveTypes.Float64, schema.Field(2).Type)
+	assert.Equal(t, arrow.FixedWidthTypes.Boolean, schema.Field(3).Type)
+	assert.Equal(t, arrow.FixedWidthTypes.Date32, schema.Field(4).Type)
+	assert.Equal(t, arrow.TIMESTAMP, schema.Field(5).Type.ID())
+
+	ids := table.Column(0).Data().Chunk(0).(*array.Int64)
+	assert.Equal(t, []int64{1, 2}, ids.Int64Values())
+	names := table.Column(1).Data().Chunk(0).(*array.String)
+	assert.Equal(t, `a "quoted", name`, names.Value(0))
+	assert.True(t, names.IsNull(1))
+	at := table.Column(5).Data().Chunk(0).(*array.Timestamp)
+	assert.Equal(t, arrow.Timestamp(1709296200250), at.Value(0))
+}
+
+func TestExport_Complex(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(context.Background(), db, "select complex", ExportCSV, &buf))
+	assert.Equal(t, strings.Join([]string{
+		"price,tags,attrs,point",
+		`12.34,"[a, null]","{k=v, n=null}","{x=1, y=2}"`,
+		",[],,",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, Export(context.Background(), db, "select complex", ExportNDJSON, &buf))
+	assert.Equal(t, strings.Join([]string{
+		`{"price":12.34,"tags":["a",null],"attrs":{"k":"v","n":null},"point":{"x":"1","y":"2"}}`,
+		`{"price":null,"tags":[],"attrs":null,"point":null}`,
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, Export(context.Background(), db, "select complex", ExportParquet, &buf))
+	pf, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer pf.Close()
+	reader, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	table, err := reader.ReadTable(context.Background())
+	require.NoError(t, err)
+	defer table.Release()
+
+	require.EqualValues(t, 2, table.NumRows())
+	// Decimals keep their precision.
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, table.Schema().Field(0).Type)
+	prices := table.Column(0).Data().Chunk(0).(*array.Decimal128)
+	assert.Equal(t, "12.34", prices.ValueStr(0))
+	tags := table.Column(1).Data().Chunk(0).(*array.List)
+	assert.Equal(t, `["a",null]`, tags.ValueStr(0))
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	err := Export(context.Background(), db, "typed", ExportFormat(42), &bytes.Buffer{})
+	assert.EqualError(t, err, "athena: unknown export format ExportFormat(42)")
+}
@@ -0,0 +1,172 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultHistoryExecutions is the default of HistoryReuseConfig.MaxExecutions.
+const defaultHistoryExecutions = 50
+
+// maxBatchGetExecutions is the most executions BatchGetQueryExecution gets.
+const maxBatchGetExecutions = 50
+
+// HistoryReuseConfig configures the reuse of the results of earlier
+// executions found in the history of the workgroup, rather than running the
+// same query again. Unlike ResultReuseConfig it works with any query and
+// engine version, and unlike a ResultCache it shares results across
+// processes without any storage of their own, at the cost of a few API
+// calls per query.
+//
+// Only SELECT queries reuse earlier executions, of the exact same SQL (up to
+// whitespace) run against the same catalog and database.
+type HistoryReuseConfig struct {
+	// MaxAge is how long ago an execution may have completed for its
+	// results to be reused. Zero disables history reuse.
+	MaxAge time.Duration
+
+	// MaxExecutions bounds the number of recent executions searched.
+	// Defaults to 50.
+	MaxExecutions int
+}
+
+// findInHistory returns a recent successful execution of query in the
+// history of the workgroup, if any. Failing to search the history isn't an
+// error, the query is then run as usual.
+func (c *conn) findInHistory(ctx context.Context, query string, opts QueryOptions) (qe *types.QueryExecution, ok bool) {
+	if c.historyReuse.MaxAge <= 0 || !isReadQuery(query) {
+		return nil, false
+	}
+
+	ctx, span := c.tracer.Start(ctx, "athena.history_lookup", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	qe, err := c.searchHistory(ctx, query, opts)
+	if err != nil {
+		span.RecordError(err)
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "athena: searching query history failed",
+			slog.String("workgroup", opts.WorkGroup),
+			slog.Any("error", err),
+		)
+		return nil, false
+	}
+	if qe == nil {
+		return nil, false
+	}
+
+	span.SetAttributes(attrQueryID.String(aws.ToString(qe.QueryExecutionId)))
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "athena: reusing earlier execution",
+		slog.String("query_id", aws.ToString(qe.QueryExecutionId)),
+		slog.String("query", redactQuery(query)),
+		slog.String("workgroup", opts.WorkGroup),
+	)
+	return qe, true
+}
+
+// errHistoryExhausted stops the search of the history once executions are
+// older than the max age.
+var errHistoryExhausted = errors.New("history exhausted")
+
+func (c *conn) searchHistory(ctx context.Context, query string, opts QueryOptions) (*types.QueryExecution, error) {
+	limit := c.historyReuse.MaxExecutions
+	if limit <= 0 {
+		limit = defaultHistoryExecutions
+	}
+	normalized := normalizeQuery(query)
+	since := time.Now().Add(-c.historyReuse.MaxAge)
+
+	input := &athena.ListQueryExecutionsInput{
+		MaxResults: aws.Int32(int32(min(limit, maxBatchGetExecutions))),
+	}
+	if opts.WorkGroup != "" {
+		input.WorkGroup = aws.String(opts.WorkGroup)
+	}
+
+	for searched := 0; searched < limit; {
+		list, err := c.athena.ListQueryExecutions(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := list.QueryExecutionIds
+		if len(ids) > limit-searched {
+			ids = ids[:limit-searched]
+		}
+		searched += len(ids)
+		if len(ids) == 0 {
+			return nil, nil
+		}
+
+		batch, err := c.athena.BatchGetQueryExecution(ctx, &athena.BatchGetQueryExecutionInput{
+			QueryExecutionIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qe, err := matchHistory(batch.QueryExecutions, normalized, opts, since)
+		if err == errHistoryExhausted {
+			return nil, nil
+		}
+		if qe != nil {
+			return qe, nil
+		}
+
+		if aws.ToString(list.NextToken) == "" {
+			return nil, nil
+		}
+		input.NextToken = list.NextToken
+	}
+	return nil, nil
+}
+
+// matchHistory returns the first of executions that ran query with opts
+// successfully since the given time. Executions are listed most recent
+// first, so it returns errHistoryExhausted once they're all older.
+func matchHistory(executions []types.QueryExecution, query string, opts QueryOptions, since time.Time) (*types.QueryExecution, error) {
+	recent := false
+	for i := range executions {
+		qe := &executions[i]
+		if qe.Status == nil {
+			continue
+		}
+		if submitted := aws.ToTime(qe.Status.SubmissionDateTime); !submitted.Before(since) {
+			recent = true
+		}
+
+		if qe.Status.State != types.QueryExecutionStateSucceeded ||
+			aws.ToTime(qe.Status.CompletionDateTime).Before(since) ||
+			normalizeQuery(aws.ToString(qe.Query)) != query {
+			continue
+		}
+
+		var database, catalog string
+		if ctx := qe.QueryExecutionContext; ctx != nil {
+			database, catalog = aws.ToString(ctx.Database), aws.ToString(ctx.Catalog)
+		}
+		if database == opts.Database && strings.EqualFold(catalogOrDefault(catalog), catalogOrDefault(opts.Catalog)) {
+			return qe, nil
+		}
+	}
+
+	if !recent && len(executions) > 0 {
+		return nil, errHistoryExhausted
+	}
+	return nil, nil
+}
+
+// catalogOrDefault returns catalog, or the catalog Athena defaults to.
+func catalogOrDefault(catalog string) string {
+	if catalog == "" {
+		return "AwsDataCatalog"
+	}
+	return catalog
+}
@@ -0,0 +1,113 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pastExecution(id, query, database string, state types.QueryExecutionState, age time.Duration) types.QueryExecution {
+	completed := time.Now().Add(-age)
+	return types.QueryExecution{
+		QueryExecutionId:      aws.String(id),
+		Query:                 aws.String(query),
+		QueryExecutionContext: &types.QueryExecutionContext{Database: aws.String(database)},
+		StatementType:         types.StatementTypeDml,
+		Status: &types.QueryExecutionStatus{
+			State:              state,
+			SubmissionDateTime: aws.Time(completed.Add(-time.Second)),
+			CompletionDateTime: aws.Time(completed),
+		},
+	}
+}
+
+func TestConn_HistoryReuse(t *testing.T) {
+	fake := newFakeAthena()
+	fake.history = []types.QueryExecution{
+		pastExecution("failed", "select", "test_db", types.QueryExecutionStateFailed, time.Minute),
+		pastExecution("other-db", "select", "other_db", types.QueryExecutionStateSucceeded, time.Minute),
+		pastExecution("match", "select", "test_db", types.QueryExecutionStateSucceeded, 2*time.Minute),
+		pastExecution("older", "select", "test_db", types.QueryExecutionStateSucceeded, 3*time.Minute),
+	}
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		HistoryReuse: HistoryReuseConfig{MaxAge: time.Hour},
+	}))
+
+	var info ExecutionInfo
+	ctx := WithExecutionInfo(context.Background(), &info)
+	assert.Len(t, readAll(t, db, ctx, "select"), 9)
+	assert.Empty(t, fake.started)
+	assert.True(t, info.FromHistory)
+	assert.Equal(t, QueryID("match"), info.QueryID)
+
+	// Only SELECT queries reuse earlier executions.
+	readAll(t, db, ctx, "show")
+	assert.Len(t, fake.started, 1)
+	assert.False(t, info.FromHistory)
+}
+
+func TestConn_HistoryReuseMaxAge(t *testing.T) {
+	fake := newFakeAthena()
+	fake.history = []types.QueryExecution{
+		pastExecution("old", "select", "test_db", types.QueryExecutionStateSucceeded, 2*time.Hour),
+	}
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		HistoryReuse: HistoryReuseConfig{MaxAge: time.Hour},
+	}))
+
+	var info ExecutionInfo
+	readAll(t, db, WithExecutionInfo(context.Background(), &info), "select")
+	assert.Len(t, fake.started, 1)
+	assert.False(t, info.FromHistory)
+}
+
+func TestConn_HistoryReuseMaxExecutions(t *testing.T) {
+	fake := newFakeAthena()
+	for i := 0; i < 5; i++ {
+		fake.history = append(fake.history, pastExecution("running", "select", "test_db", types.QueryExecutionStateRunning, 0))
+	}
+	fake.history = append(fake.history, pastExecution("match", "select", "test_db", types.QueryExecutionStateSucceeded, time.Minute))
+
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		HistoryReuse: HistoryReuseConfig{MaxAge: time.Hour, MaxExecutions: 5},
+	}))
+	readAll(t, db, context.Background(), "select")
+	assert.Len(t, fake.started, 1)
+}
+
+func TestMatchHistory(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	opts := QueryOptions{Database: "test_db"}
+
+	old := []types.QueryExecution{
+		pastExecution("old", "select 2", "test_db", types.QueryExecutionStateSucceeded, 2*time.Hour),
+	}
+	_, err := matchHistory(old, "select", opts, since)
+	assert.Equal(t, errHistoryExhausted, err)
+
+	catalog := []types.QueryExecution{
+		pastExecution("catalog", "select", "test_db", types.QueryExecutionStateSucceeded, time.Minute),
+	}
+	catalog[0].QueryExecutionContext.Catalog = aws.String("awsdatacatalog")
+	qe, err := matchHistory(catalog, "select", opts, since)
+	require.NoError(t, err)
+	require.NotNil(t, qe)
+	assert.Equal(t, "catalog", aws.ToString(qe.QueryExecutionId))
+
+	qe, err = matchHistory(catalog, "select", QueryOptions{Database: "test_db", Catalog: "other"}, since)
+	require.NoError(t, err)
+	assert.Nil(t, qe)
+
+	// The line break ending a comment is part of the query.
+	commented := []types.QueryExecution{
+		pastExecution("commented", "SELECT 1 -- note , 2", "test_db", types.QueryExecutionStateSucceeded, time.Minute),
+	}
+	qe, err = matchHistory(commented, normalizeQuery("SELECT 1 -- note\n, 2"), opts, since)
+	require.NoError(t, err)
+	assert.Nil(t, qe)
+}
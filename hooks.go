@@ -0,0 +1,155 @@
+package athena
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// Hooks are notified of the lifecycle of queries, e.g. for logging, metrics
+// or auditing. Set them on DriverConfig.Hooks.
+//
+// Hooks are called one at a time, in the order the events happened, on a
+// goroutine of their own: a slow hook delays the hooks called after it, but
+// never the queries themselves. For a query run with `db.QueryContext`, the
+// hooks are called in this order:
+//
+//   - OnStart, once the query is submitted.
+//   - OnStateChange, every time polling sees the query in a new state.
+//   - OnCancel, if the driver stops the query before it finishes.
+//   - OnFinish, once the driver is done waiting on the query.
+//   - OnPage, for every page of results read.
+//
+// OnFinish is called once per query the driver waits on. A query started
+// with Client.Start only finishes once Client.Wait is called on it: if it
+// never is, the hooks see it start but never finish. Queries the driver
+// runs on its own behalf, such as the EXPLAIN checking the estimate of
+// DriverConfig.CheckScanEstimates, aren't reported to the hooks.
+//
+// info is nil when the driver has no execution to report, e.g. OnFinish of
+// a query that failed to be polled. Hooks must check it before use.
+//
+// Events wait in an unbounded queue until the hooks are called: hooks much
+// slower than the queries they observe make it grow without limit.
+//
+// Embed NopHooks to implement only some of them. Hooks may also implement
+// CleanupHooks.
+type Hooks interface {
+	OnStart(queryID QueryID, query string)
+	OnStateChange(queryID QueryID, state types.QueryExecutionState, info *ExecutionInfo)
+	OnCancel(queryID QueryID, reason error)
+	OnFinish(queryID QueryID, err error, info *ExecutionInfo)
+	OnPage(queryID QueryID, rows int)
+}
+
+// NopHooks implements Hooks, doing nothing.
+type NopHooks struct{}
+
+func (NopHooks) OnStart(QueryID, string)                                          {}
+func (NopHooks) OnStateChange(QueryID, types.QueryExecutionState, *ExecutionInfo) {}
+func (NopHooks) OnCancel(QueryID, error)                                          {}
+func (NopHooks) OnFinish(QueryID, error, *ExecutionInfo)                          {}
+func (NopHooks) OnPage(QueryID, int)                                              {}
+
+// hookDispatcher calls hooks asynchronously, preserving the order of events.
+// A nil *hookDispatcher drops every event.
+type hookDispatcher struct {
+	hooks Hooks
+
+	mu      sync.Mutex
+	queue   []func(Hooks)
+	running bool
+	idle    *sync.Cond
+}
+
+func newHookDispatcher(hooks Hooks) *hookDispatcher {
+	if hooks == nil {
+		return nil
+	}
+
+	d := &hookDispatcher{hooks: hooks}
+	d.idle = sync.NewCond(&d.mu)
+	return d
+}
+
+// dispatch queues a call to the hooks. It never blocks on the hooks.
+func (d *hookDispatcher) dispatch(call func(Hooks)) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.queue = append(d.queue, call)
+	if !d.running {
+		d.running = true
+		go d.run()
+	}
+}
+
+// run calls the queued hooks until the queue is empty.
+func (d *hookDispatcher) run() {
+	for {
+		d.mu.Lock()
+		if len(d.queue) == 0 {
+			d.running = false
+			d.idle.Broadcast()
+			d.mu.Unlock()
+			return
+		}
+
+		call := d.queue[0]
+		d.queue[0] = nil
+		d.queue = d.queue[1:]
+		d.mu.Unlock()
+
+		call(d.hooks)
+	}
+}
+
+// wait blocks until every queued hook was called.
+func (d *hookDispatcher) wait() {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.running {
+		d.idle.Wait()
+	}
+}
+
+func (d *hookDispatcher) onStart(queryID string, query string) {
+	d.dispatch(func(h Hooks) { h.OnStart(QueryID(queryID), query) })
+}
+
+func (d *hookDispatcher) onStateChange(qe *types.QueryExecution) {
+	if d == nil {
+		return
+	}
+
+	info := newExecutionInfo(qe)
+	d.dispatch(func(h Hooks) { h.OnStateChange(info.QueryID, info.State, info) })
+}
+
+func (d *hookDispatcher) onCancel(queryID string, reason error) {
+	d.dispatch(func(h Hooks) { h.OnCancel(QueryID(queryID), reason) })
+}
+
+func (d *hookDispatcher) onFinish(queryID string, err error, info *ExecutionInfo) {
+	d.dispatch(func(h Hooks) { h.OnFinish(QueryID(queryID), err, info) })
+}
+
+func (d *hookDispatcher) onPage(queryID string, rows int) {
+	d.dispatch(func(h Hooks) { h.OnPage(QueryID(queryID), rows) })
+}
+
+func (d *hookDispatcher) onCleanupError(objects []string, err error) {
+	d.dispatch(func(h Hooks) {
+		if cleanup, ok := h.(CleanupHooks); ok {
+			cleanup.OnCleanupError(objects, err)
+		}
+	})
+}
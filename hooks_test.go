@@ -0,0 +1,149 @@
+package athena
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHooks records the hooks called, and blocks in OnStart until
+// release is closed, if set.
+type recordingHooks struct {
+	release chan struct{}
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHooks) record(format string, args ...interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, fmt.Sprintf(format, args...))
+}
+
+func (h *recordingHooks) OnStart(queryID QueryID, query string) {
+	if h.release != nil {
+		<-h.release
+	}
+	h.record("start %s %s", queryID, query)
+}
+
+func (h *recordingHooks) OnStateChange(queryID QueryID, state types.QueryExecutionState, info *ExecutionInfo) {
+	h.record("state %s %s", queryID, state)
+}
+
+func (h *recordingHooks) OnCancel(queryID QueryID, reason error) {
+	h.record("cancel %s %v", queryID, reason)
+}
+
+func (h *recordingHooks) OnFinish(queryID QueryID, err error, info *ExecutionInfo) {
+	if info == nil {
+		h.record("finish %s %v", queryID, err)
+		return
+	}
+	h.record("finish %s %v %d", queryID, err, info.DataScannedInBytes)
+}
+
+// finished returns the events of queries finishing.
+func (h *recordingHooks) finished() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var finished []string
+	for _, event := range h.events {
+		if strings.HasPrefix(event, "finish ") {
+			finished = append(finished, event)
+		}
+	}
+	return finished
+}
+
+func (h *recordingHooks) OnPage(queryID QueryID, rows int) {
+	h.record("page %s %d", queryID, rows)
+}
+
+func TestHooks(t *testing.T) {
+	hooks := &recordingHooks{release: make(chan struct{})}
+	fake := newFakeAthena(types.QueryExecutionStateQueued, types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	connector := newTestConnector(t, fake, DriverConfig{Hooks: hooks})
+	db := openTestConnector(t, connector)
+
+	// OnStart blocks until the query is fully read, which must not block the
+	// query itself.
+	rows, err := db.QueryContext(context.Background(), "select")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	close(hooks.release)
+	connector.hooks.wait()
+
+	assert.Equal(t, []string{
+		"start query-1 select",
+		"state query-1 QUEUED",
+		"state query-1 RUNNING",
+		"state query-1 SUCCEEDED",
+		"finish query-1 <nil> 3072",
+		"page query-1 4",
+		"page query-1 5",
+	}, hooks.events)
+}
+
+func TestHooks_Cancel(t *testing.T) {
+	hooks := &recordingHooks{}
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	connector := newTestConnector(t, fake, DriverConfig{Hooks: hooks})
+	db := openTestConnector(t, connector)
+
+	ctx := WithQueryOptions(context.Background(), QueryOptions{MaxBytesScanned: 1500})
+	_, err := db.ExecContext(ctx, "select")
+	require.ErrorIs(t, err, ErrScanBudgetExceeded)
+
+	connector.hooks.wait()
+	assert.Equal(t, []string{
+		"start query-1 select",
+		"state query-1 RUNNING",
+		"cancel query-1 " + err.Error(),
+		"finish query-1 " + err.Error() + " 2048",
+	}, hooks.events)
+}
+
+func TestHooks_ScanEstimate(t *testing.T) {
+	hooks := &recordingHooks{}
+	fake := newFakeAthena(types.QueryExecutionStateSucceeded)
+	connector := newTestConnector(t, fake, DriverConfig{
+		Hooks:              hooks,
+		CheckScanEstimates: true,
+		MaxBytesScanned:    10000,
+	})
+	db := openTestConnector(t, connector)
+
+	// The EXPLAIN checking the estimate isn't reported, only the query.
+	var progress []string
+	ctx := WithProgress(context.Background(), func(p Progress) {
+		progress = append(progress, string(p.QueryID))
+	})
+	_, err := db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+	require.Len(t, fake.started, 2)
+
+	connector.hooks.wait()
+	assert.Equal(t, []string{
+		"start query-2 select",
+		"state query-2 SUCCEEDED",
+		"finish query-2 <nil> 1024",
+		"page query-2 4",
+	}, hooks.events)
+	require.NotEmpty(t, progress)
+	for _, queryID := range progress {
+		assert.Equal(t, "query-2", queryID)
+	}
+}
@@ -0,0 +1,109 @@
+//go:build go1.23
+
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+)
+
+// Rows runs query on db and returns an iterator over the rows of its
+// results, as maps from column names to the values returned by the driver:
+//
+//	for row, err := range athena.Rows(ctx, db, "SELECT * FROM events") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The query runs when the iteration starts. An error ends the iteration.
+// Once the loop ends, including when it breaks early, the results are closed
+// and the query's context is canceled, which stops it if it's still running.
+// The driver doesn't support query arguments, so passing any args yields an
+// error.
+func Rows(ctx context.Context, db *sql.DB, query string, args ...any) iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		if len(args) > 0 {
+			yield(nil, errArgsNotSupported)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			row := make(map[string]any, len(columns))
+			for i, col := range columns {
+				row[col] = values[i]
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Structs runs query on db and returns an iterator over the rows of its
+// results scanned into values of T, like StructIterator. It behaves like
+// Rows otherwise, including yielding an error if any args are passed.
+func Structs[T any](ctx context.Context, db *sql.DB, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		it, err := QueryStructIterator[T](ctx, db, query, args...)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+
+		it.All()(yield)
+	}
+}
+
+// All returns an iterator over the remaining rows of it, which is closed
+// once the loop ends.
+func (it *StructIterator[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer it.Close()
+
+		for it.Next() {
+			if !yield(it.Value(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//go:build go1.23
+
+package athena
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRows(t *testing.T) {
+	db := openTestDB(t, newFakeAthena())
+
+	var rows []map[string]any
+	for row, err := range Rows(context.Background(), db, "typed") {
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(1), rows[0]["id"])
+	assert.Equal(t, true, rows[0]["active"])
+	assert.Equal(t, map[string]any{
+		"id": int64(2), "name": nil, "score": nil, "active": nil, "day": nil, "at": nil,
+	}, rows[1])
+}
+
+func TestRows_Break(t *testing.T) {
+	db := openTestDB(t, newFakeAthena())
+
+	n := 0
+	for _, err := range Rows(context.Background(), db, "select") {
+		require.NoError(t, err)
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, n)
+	// The results were closed, releasing the connection.
+	assert.Equal(t, 0, db.Stats().InUse)
+}
+
+func TestRows_Error(t *testing.T) {
+	db := openTestDB(t, newFakeAthena())
+
+	var errs []error
+	for row, err := range Rows(context.Background(), db, "iteration_fail") {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		assert.NotNil(t, row)
+	}
+	require.Len(t, errs, 1)
+	assert.Equal(t, dummyError, errs[0])
+
+	// Arguments are an error rather than a panic of the driver.
+	errs = nil
+	for _, err := range Rows(context.Background(), db, "typed", 1) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{errArgsNotSupported}, errs)
+}
+
+func TestStructs(t *testing.T) {
+	db := openTestDB(t, newFakeAthena())
+
+	var ids []int32
+	for row, err := range Structs[typedRow](context.Background(), db, "typed") {
+		require.NoError(t, err)
+		ids = append(ids, row.ID)
+	}
+	assert.Equal(t, []int32{1, 2}, ids)
+
+	for _, err := range Structs[struct{ ID int }](context.Background(), db, "typed") {
+		assert.EqualError(t, err, `athena: column "name" has no field in struct { ID int }`)
+	}
+
+	for _, err := range Structs[typedRow](context.Background(), db, "typed", 1) {
+		assert.Equal(t, errArgsNotSupported, err)
+	}
+
+	for range Structs[typedRow](context.Background(), db, "typed") {
+		break
+	}
+	assert.Equal(t, 0, db.Stats().InUse)
+}
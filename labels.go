@@ -0,0 +1,54 @@
+package athena
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type labelsKey struct{}
+
+// WithLabels returns a context attaching labels to the queries run with it,
+// e.g. the feature or team they're run for. Queries are accounted for per
+// set of labels in Connector.Stats. Labels set by an outer WithLabels call
+// are kept unless overridden.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := make(map[string]string, len(labels))
+	for k, v := range labelsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsKey{}, merged)
+}
+
+// labelsFromContext returns the labels attached to ctx. The map must not be
+// modified.
+func labelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+// labelsKeyOf returns a canonical string identifying a set of labels. Names
+// and values are quoted, so that commas and equal signs in them can't make
+// distinct sets look the same.
+func labelsKeyOf(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(k))
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package athena
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"golang.org/x/time/rate"
+)
+
+// LimiterConfig configures a Limiter. Zero values disable the corresponding
+// limit.
+type LimiterConfig struct {
+	// MaxConcurrentQueries bounds the number of queries running at once.
+	// Queries over the limit wait for a running query to finish first.
+	MaxConcurrentQueries int
+
+	// APIRequestsPerSecond bounds the rate of calls to the Athena API, with
+	// bursts of up to APIBurst calls (at least 1).
+	APIRequestsPerSecond float64
+	APIBurst             int
+
+	// PriorityAging protects queries waiting for MaxConcurrentQueries from
+	// starvation: every PriorityAging a query waits raises its priority by
+	// one. Defaults to 1m, negative values disable aging.
+	PriorityAging time.Duration
+}
+
+// defaultPriorityAging is the default of LimiterConfig.PriorityAging.
+const defaultPriorityAging = time.Minute
+
+// Limiter bounds the queries run and API calls made through the drivers
+// sharing it, to stay within Athena's quotas rather than being throttled.
+// As quotas apply per account, region and workgroup, create a Limiter per
+// quota and set it on the DriverConfig of every DB it applies to.
+//
+// Queries waiting to run are admitted by priority (see WithPriority), then in
+// the order they arrived. Callers waiting on the limiter give up once their
+// context is done.
+// Queries started with Client.Start aren't counted against
+// MaxConcurrentQueries, as the driver doesn't know when they finish.
+type Limiter struct {
+	rate *rate.Limiter
+
+	mu      sync.Mutex
+	max     int
+	aging   time.Duration
+	running int
+	waiters list.List // of *waiter, in arrival order
+}
+
+// waiter is a query waiting for the limiter to admit it.
+type waiter struct {
+	priority Priority
+	since    time.Time
+	admitted chan struct{}
+}
+
+// LimiterStats are a snapshot of the queries going through a Limiter.
+type LimiterStats struct {
+	Running int
+	Waiting map[Priority]int
+}
+
+// NewLimiter returns a Limiter for cfg.
+func NewLimiter(cfg LimiterConfig) (*Limiter, error) {
+	if cfg.MaxConcurrentQueries < 0 {
+		return nil, errors.New("MaxConcurrentQueries must not be negative")
+	}
+	if cfg.APIRequestsPerSecond < 0 {
+		return nil, errors.New("APIRequestsPerSecond must not be negative")
+	}
+
+	l := &Limiter{max: cfg.MaxConcurrentQueries, aging: cfg.PriorityAging}
+	if l.aging == 0 {
+		l.aging = defaultPriorityAging
+	}
+	if cfg.APIRequestsPerSecond > 0 {
+		l.rate = rate.NewLimiter(rate.Limit(cfg.APIRequestsPerSecond), max(cfg.APIBurst, 1))
+	}
+	return l, nil
+}
+
+// acquire blocks until a query of the given priority may run, returning a
+// func to call once it's finished. A nil *Limiter admits every query.
+func (l *Limiter) acquire(ctx context.Context, priority Priority) (release func(), err error) {
+	if l == nil || l.max <= 0 {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	if l.running < l.max && l.waiters.Len() == 0 {
+		l.running++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+
+	w := &waiter{priority: priority, since: time.Now(), admitted: make(chan struct{})}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.admitted:
+		return l.release, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		select {
+		case <-w.admitted:
+			// Admitted in the meantime: pass the slot on.
+			l.mu.Unlock()
+			l.release()
+		default:
+			l.waiters.Remove(elem)
+			l.mu.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release frees the slot of a finished query, admitting the waiting query
+// with the highest priority, if any.
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.running--
+	if next := l.next(time.Now()); next != nil {
+		l.waiters.Remove(next)
+		l.running++
+		close(next.Value.(*waiter).admitted)
+	}
+}
+
+// next returns the waiter to admit next, the first to arrive among those
+// with the highest priority once aged.
+func (l *Limiter) next(now time.Time) *list.Element {
+	var best *list.Element
+	var bestPriority Priority
+	for e := l.waiters.Front(); e != nil; e = e.Next() {
+		w := e.Value.(*waiter)
+		priority := w.priority
+		if l.aging > 0 {
+			priority += Priority(now.Sub(w.since) / l.aging)
+		}
+		if best == nil || priority > bestPriority {
+			best, bestPriority = e, priority
+		}
+	}
+	return best
+}
+
+// Stats returns the number of queries running and waiting to run.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := LimiterStats{Running: l.running, Waiting: map[Priority]int{}}
+	for e := l.waiters.Front(); e != nil; e = e.Next() {
+		stats.Waiting[e.Value.(*waiter).priority]++
+	}
+	return stats
+}
+
+// wait blocks until an API call may be made.
+func (l *Limiter) wait(ctx context.Context) error {
+	if l == nil || l.rate == nil {
+		return nil
+	}
+	return l.rate.Wait(ctx)
+}
+
+// wrap returns api with its calls rate limited by l.
+func (l *Limiter) wrap(api athenaAPI) athenaAPI {
+	if l == nil || l.rate == nil {
+		return api
+	}
+	return &limitedAPI{api: api, limiter: l}
+}
+
+// limitedAPI is an athenaAPI whose calls are rate limited.
+type limitedAPI struct {
+	api     athenaAPI
+	limiter *Limiter
+}
+
+func (a *limitedAPI) BatchGetQueryExecution(ctx context.Context, params *athena.BatchGetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.BatchGetQueryExecutionOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.BatchGetQueryExecution(ctx, params, optFns...)
+}
+
+func (a *limitedAPI) GetQueryExecution(ctx context.Context, params *athena.GetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetQueryExecution(ctx, params, optFns...)
+}
+
+func (a *limitedAPI) GetQueryRuntimeStatistics(ctx context.Context, params *athena.GetQueryRuntimeStatisticsInput, optFns ...func(*athena.Options)) (*athena.GetQueryRuntimeStatisticsOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetQueryRuntimeStatistics(ctx, params, optFns...)
+}
+
+func (a *limitedAPI) GetQueryResults(ctx context.Context, params *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.GetQueryResults(ctx, params, optFns...)
+}
+
+func (a *limitedAPI) ListQueryExecutions(ctx context.Context, params *athena.ListQueryExecutionsInput, optFns ...func(*athena.Options)) (*athena.ListQueryExecutionsOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.ListQueryExecutions(ctx, params, optFns...)
+}
+
+func (a *limitedAPI) StartQueryExecution(ctx context.Context, params *athena.StartQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.StartQueryExecution(ctx, params, optFns...)
+}
+
+func (a *limitedAPI) StopQueryExecution(ctx context.Context, params *athena.StopQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StopQueryExecutionOutput, error) {
+	if err := a.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.api.StopQueryExecution(ctx, params, optFns...)
+}
+
+var _ athenaAPI = (*limitedAPI)(nil)
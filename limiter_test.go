@@ -0,0 +1,153 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waiting returns the number of queries waiting for limiter.
+func waiting(limiter *Limiter) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.waiters.Len()
+}
+
+func TestLimiter_MaxConcurrentQueries(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{MaxConcurrentQueries: 1})
+	require.NoError(t, err)
+
+	release, err := limiter.acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.acquire(ctx, PriorityNormal)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	admitted := make(chan struct{})
+	go func() {
+		release, err := limiter.acquire(context.Background(), PriorityNormal)
+		assert.NoError(t, err)
+		release()
+		close(admitted)
+	}()
+
+	release()
+	<-admitted
+	assert.Equal(t, 0, limiter.running)
+	assert.Equal(t, 0, limiter.waiters.Len())
+}
+
+func TestLimiter_Priority(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{MaxConcurrentQueries: 1})
+	require.NoError(t, err)
+
+	release, err := limiter.acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+
+	admitted := make(chan Priority, 2)
+	for i, priority := range []Priority{PriorityLow, PriorityHigh} {
+		go func(priority Priority) {
+			release, err := limiter.acquire(context.Background(), priority)
+			assert.NoError(t, err)
+			admitted <- priority
+			release()
+		}(priority)
+		// Make sure the low priority query arrives first.
+		require.Eventually(t, func() bool { return waiting(limiter) == i+1 }, time.Second, time.Millisecond)
+	}
+	assert.Equal(t, LimiterStats{Running: 1, Waiting: map[Priority]int{PriorityLow: 1, PriorityHigh: 1}}, limiter.Stats())
+
+	release()
+	assert.Equal(t, PriorityHigh, <-admitted)
+	assert.Equal(t, PriorityLow, <-admitted)
+}
+
+func TestLimiter_PriorityAging(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{MaxConcurrentQueries: 1})
+	require.NoError(t, err)
+
+	now := time.Now()
+	low := &waiter{priority: PriorityLow, since: now.Add(-150 * time.Second)}
+	high := &waiter{priority: PriorityHigh, since: now.Add(-10 * time.Second)}
+	limiter.waiters.PushBack(low)
+	limiter.waiters.PushBack(high)
+
+	// Having waited over two minutes, the low priority query is now on par
+	// with the high priority one, and arrived first.
+	assert.Same(t, low, limiter.next(now).Value)
+	assert.Same(t, high, limiter.next(now.Add(-time.Minute)).Value)
+}
+
+func TestLimiter_SharedByDBs(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{MaxConcurrentQueries: 1})
+	require.NoError(t, err)
+
+	running := newFakeAthena(types.QueryExecutionStateRunning)
+	connector := newTestConnector(t, running, DriverConfig{Limiter: limiter})
+	first := openTestConnector(t, connector)
+	second := newFakeAthena()
+	other := openTestConnector(t, newTestConnector(t, second, DriverConfig{Limiter: limiter}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		first.ExecContext(context.Background(), "select")
+	}()
+	require.Eventually(t, func() bool { return inFlight(connector) == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = other.ExecContext(ctx, "select")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, second.started)
+
+	require.NoError(t, connector.Shutdown(context.Background()))
+	<-done
+	_, err = other.ExecContext(context.Background(), "select")
+	assert.NoError(t, err)
+}
+
+func TestLimiter_APIRate(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{APIRequestsPerSecond: 20})
+	require.NoError(t, err)
+
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{Limiter: limiter}))
+
+	// The first call uses up the burst, and the next one can't be made
+	// before the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "select")
+	assert.Error(t, err)
+	assert.Len(t, fake.started, 1)
+}
+
+func TestLimiter_ExecutionInfo(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{MaxConcurrentQueries: 1})
+	require.NoError(t, err)
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{Limiter: limiter}))
+
+	var info ExecutionInfo
+	ctx := WithPriority(WithExecutionInfo(context.Background(), &info), PriorityHigh)
+	_, err = db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityHigh, info.Priority)
+	assert.Equal(t, LimiterStats{Running: 0, Waiting: map[Priority]int{}}, limiter.Stats())
+}
+
+func TestConn_PollingThrottled(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	fake.pollErrs = []error{&types.TooManyRequestsException{}, &types.TooManyRequestsException{}}
+	db := openTestDB(t, fake)
+
+	_, err := db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+	assert.Empty(t, fake.stopped)
+}
@@ -0,0 +1,61 @@
+package athena
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLoggedQueryLength is the length past which queries are truncated in logs.
+const maxLoggedQueryLength = 256
+
+// discardHandler is a slog.Handler dropping every record, used when no
+// logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func newLogger(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return logger
+}
+
+// redactQuery returns query fit for logs: its string literals are replaced
+// with '?', as they're the likeliest to hold sensitive values, and it's
+// truncated to maxLoggedQueryLength bytes, on a character boundary.
+func redactQuery(query string) string {
+	var b strings.Builder
+	inLiteral := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' && inLiteral && i+1 < len(query) && query[i+1] == '\'':
+			// An escaped quote within a literal.
+			i++
+		case ch == '\'':
+			inLiteral = !inLiteral
+			if inLiteral {
+				b.WriteString("'?'")
+			}
+		case !inLiteral:
+			b.WriteByte(ch)
+		}
+	}
+
+	redacted := strings.Join(strings.Fields(b.String()), " ")
+	if len(redacted) > maxLoggedQueryLength {
+		// Don't split a multi-byte character.
+		end := maxLoggedQueryLength
+		for end > 0 && !utf8.RuneStart(redacted[end]) {
+			end--
+		}
+		redacted = redacted[:end] + "..."
+	}
+	return redacted
+}
@@ -0,0 +1,78 @@
+package athena
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT *\n\tFROM users WHERE email = 'bob@example.com'", "SELECT * FROM users WHERE email = '?'"},
+		{"SELECT 'it''s', 'a' || 'b'", "SELECT '?', '?' || '?'"},
+		{"SELECT " + strings.Repeat("x", 300), "SELECT " + strings.Repeat("x", maxLoggedQueryLength-7) + "..."},
+		// Truncated before the character the limit falls in.
+		{"SELECT " + strings.Repeat("é", 300), "SELECT " + strings.Repeat("é", (maxLoggedQueryLength-7)/2) + "..."},
+	}
+
+	for _, test := range tests {
+		redacted := redactQuery(test.query)
+		assert.Equal(t, test.expected, redacted, test.query)
+		assert.True(t, utf8.ValidString(redacted), test.query)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	fake.startErrs = []error{&types.TooManyRequestsException{}}
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{Logger: logger}))
+
+	rows, err := db.QueryContext(context.Background(), "select")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+
+	logs := buf.String()
+	assert.Contains(t, logs, `level=WARN msg="athena: retrying query start" query=select attempt=1`)
+	assert.Contains(t, logs, `level=INFO msg="athena: query started" query_id=query-1 query=select database=test_db`)
+	assert.Contains(t, logs, `level=DEBUG msg="athena: query state changed" query_id=query-1 state=RUNNING`)
+	assert.Contains(t, logs, `level=DEBUG msg="athena: query state changed" query_id=query-1 state=SUCCEEDED`)
+	assert.Contains(t, logs, `level=DEBUG msg="athena: fetched results page" query_id=query-1 page=2 rows=5 last=true`)
+}
+
+func TestLogging_ScanEstimate(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		Logger:             logger,
+		CheckScanEstimates: true,
+		MaxBytesScanned:    10000,
+	}))
+
+	_, err := db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+	require.Len(t, fake.started, 2)
+
+	// The EXPLAIN checking the estimate isn't logged, only the query.
+	logs := buf.String()
+	assert.NotContains(t, logs, "query-1")
+	assert.NotContains(t, logs, "EXPLAIN")
+	assert.Contains(t, logs, `level=INFO msg="athena: query started" query_id=query-2 query=select database=test_db`)
+}
@@ -0,0 +1,311 @@
+// Package metrics exposes the activity of the go-athena driver as Prometheus
+// metrics. A *Metrics implements both athena.Hooks and prometheus.Collector:
+//
+//	m := metrics.New(metrics.Options{})
+//	prometheus.MustRegister(m)
+//	db, err := athena.Open(athena.DriverConfig{Hooks: m, ...})
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/prometheus/client_golang/prometheus"
+	athena "github.com/segmentio/go-athena"
+)
+
+// Outcomes of finished queries, used as the "outcome" label.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+)
+
+// Options configure the metrics.
+type Options struct {
+	// Namespace prefixes the name of every metric. Defaults to "athena".
+	Namespace string
+
+	// ConstLabels are added to every metric, e.g. to tell DBs apart.
+	ConstLabels prometheus.Labels
+
+	// LatencyBuckets are the buckets of the latency histograms, in seconds.
+	// Athena queries take from a few hundred milliseconds to hours, so they
+	// default to exponential buckets from 0.25s to about 2h.
+	LatencyBuckets []float64
+
+	// QueryTTL is how long a query is counted as in flight without finishing.
+	// The hooks never see queries started with Client.Start finish unless
+	// Client.Wait is called on them: they're forgotten once QueryTTL passed.
+	// Defaults to 24h.
+	QueryTTL time.Duration
+}
+
+// pruneInterval is how often the queries in flight for longer than
+// Options.QueryTTL are forgotten.
+const pruneInterval = time.Minute
+
+// Metrics collects the metrics of the queries it's notified of as hooks.
+type Metrics struct {
+	athena.NopHooks
+
+	started       *prometheus.CounterVec
+	finished      *prometheus.CounterVec
+	inFlight      *prometheus.GaugeVec
+	queueTime     *prometheus.HistogramVec
+	engineTime    *prometheus.HistogramVec
+	admissionWait *prometheus.HistogramVec
+	bytesScanned  *prometheus.CounterVec
+	pages         prometheus.Counter
+	rows          prometheus.Counter
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	queries map[athena.QueryID]*queryState
+	pruned  time.Time
+}
+
+// queryState is the state of a query in flight.
+type queryState struct {
+	// workGroup is nil until the workgroup of the query is known.
+	workGroup *string
+	started   time.Time
+}
+
+// New returns Metrics configured with opts.
+func New(opts Options) *Metrics {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "athena"
+	}
+	buckets := opts.LatencyBuckets
+	if buckets == nil {
+		buckets = prometheus.ExponentialBuckets(0.25, 2, 15)
+	}
+	ttl := opts.QueryTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return &Metrics{
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "queries_started_total",
+			Help:        "Number of queries started.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"workgroup"}),
+		finished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "queries_finished_total",
+			Help:        "Number of queries finished, by outcome and error category.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"workgroup", "outcome", "error_category"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "queries_in_flight",
+			Help:        "Number of queries started and not finished yet.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"workgroup"}),
+		queueTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "query_queue_seconds",
+			Help:        "Time queries spent queued in Athena.",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     buckets,
+		}, []string{"workgroup"}),
+		engineTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "query_execution_seconds",
+			Help:        "Time queries spent executing in the Athena engine.",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     buckets,
+		}, []string{"workgroup"}),
+		admissionWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "query_admission_wait_seconds",
+			Help:        "Time queries waited for the driver's limiter to let them run, by priority.",
+			ConstLabels: opts.ConstLabels,
+			Buckets:     buckets,
+		}, []string{"priority"}),
+		bytesScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "scanned_bytes_total",
+			Help:        "Bytes of data scanned by queries.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"workgroup"}),
+		pages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "result_pages_total",
+			Help:        "Number of pages of results fetched with GetQueryResults.",
+			ConstLabels: opts.ConstLabels,
+		}),
+		rows: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "result_rows_total",
+			Help:        "Number of rows of results fetched.",
+			ConstLabels: opts.ConstLabels,
+		}),
+		ttl:     ttl,
+		now:     time.Now,
+		queries: map[athena.QueryID]*queryState{},
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.started, m.finished, m.inFlight, m.queueTime, m.engineTime, m.admissionWait, m.bytesScanned, m.pages, m.rows,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// OnStart implements athena.Hooks.
+func (m *Metrics) OnStart(queryID athena.QueryID, query string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.prune(now)
+	m.queries[queryID] = &queryState{started: now}
+}
+
+// prune forgets the queries in flight for longer than the TTL, at most once
+// per pruneInterval.
+func (m *Metrics) prune(now time.Time) {
+	if now.Sub(m.pruned) < pruneInterval {
+		return
+	}
+	m.pruned = now
+
+	for queryID, q := range m.queries {
+		if now.Sub(q.started) < m.ttl {
+			continue
+		}
+		delete(m.queries, queryID)
+		if q.workGroup != nil {
+			m.inFlight.WithLabelValues(*q.workGroup).Dec()
+		}
+	}
+}
+
+// OnStateChange implements athena.Hooks. Queries are counted as started and
+// in flight once their workgroup is known, which is when the driver first
+// polls them, right after starting them.
+func (m *Metrics) OnStateChange(queryID athena.QueryID, state types.QueryExecutionState, info *athena.ExecutionInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queries[queryID]
+	if !ok || q.workGroup != nil || info == nil {
+		return
+	}
+
+	q.workGroup = &info.WorkGroup
+	m.started.WithLabelValues(info.WorkGroup).Inc()
+	m.inFlight.WithLabelValues(info.WorkGroup).Inc()
+}
+
+// OnFinish implements athena.Hooks.
+func (m *Metrics) OnFinish(queryID athena.QueryID, err error, info *athena.ExecutionInfo) {
+	m.mu.Lock()
+	q, ok := m.queries[queryID]
+	delete(m.queries, queryID)
+	m.mu.Unlock()
+
+	if !ok {
+		// The driver only waited on the query, e.g. to read its results, or
+		// it was forgotten after Options.QueryTTL.
+		return
+	}
+
+	wg := ""
+	switch {
+	case q.workGroup != nil:
+		wg = *q.workGroup
+		m.inFlight.WithLabelValues(wg).Dec()
+	case info != nil:
+		wg = info.WorkGroup
+		m.started.WithLabelValues(wg).Inc()
+	default:
+		m.started.WithLabelValues(wg).Inc()
+	}
+
+	m.finished.WithLabelValues(wg, outcome(err, info), errorCategory(err, info)).Inc()
+	if info == nil {
+		return
+	}
+
+	m.admissionWait.WithLabelValues(info.Priority.String()).Observe(info.AdmissionWait.Seconds())
+	m.bytesScanned.WithLabelValues(wg).Add(float64(info.DataScannedInBytes))
+	if err == nil {
+		m.queueTime.WithLabelValues(wg).Observe(info.QueryQueueTime.Seconds())
+		m.engineTime.WithLabelValues(wg).Observe(info.EngineExecutionTime.Seconds())
+	}
+}
+
+// OnPage implements athena.Hooks.
+func (m *Metrics) OnPage(queryID athena.QueryID, rows int) {
+	m.pages.Inc()
+	m.rows.Add(float64(rows))
+}
+
+func outcome(err error, info *athena.ExecutionInfo) string {
+	switch {
+	case err == nil:
+		return OutcomeSucceeded
+	case info != nil && info.State == types.QueryExecutionStateCancelled,
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, athena.ErrScanBudgetExceeded):
+		return OutcomeCancelled
+	default:
+		return OutcomeFailed
+	}
+}
+
+// errorCategory returns the "error_category" label of a finished query: the
+// category Athena reports for failed queries, or why the driver gave up on it.
+func errorCategory(err error, info *athena.ExecutionInfo) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, athena.ErrScanBudgetExceeded):
+		return "scan_budget"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+
+	if info != nil {
+		switch info.ErrorCategory {
+		case 1:
+			return "system"
+		case 2:
+			return "user"
+		case 3:
+			return "other"
+		}
+	}
+	return "unknown"
+}
+
+var _ athena.Hooks = (*Metrics)(nil)
+var _ prometheus.Collector = (*Metrics)(nil)
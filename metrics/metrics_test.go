@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	athena "github.com/segmentio/go-athena"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New(Options{LatencyBuckets: []float64{1, 10}})
+
+	succeeded := &athena.ExecutionInfo{
+		QueryID:             "q1",
+		WorkGroup:           "etl",
+		State:               types.QueryExecutionStateSucceeded,
+		DataScannedInBytes:  1024,
+		QueryQueueTime:      500 * time.Millisecond,
+		EngineExecutionTime: 5 * time.Second,
+		Priority:            athena.PriorityHigh,
+		AdmissionWait:       2 * time.Second,
+	}
+	m.OnStart("q1", "SELECT 1")
+	m.OnStateChange("q1", types.QueryExecutionStateQueued, &athena.ExecutionInfo{QueryID: "q1", WorkGroup: "etl"})
+	m.OnStateChange("q1", types.QueryExecutionStateRunning, &athena.ExecutionInfo{QueryID: "q1", WorkGroup: "etl"})
+
+	m.OnStart("q2", "SELECT 2")
+	m.OnStateChange("q2", types.QueryExecutionStateRunning, &athena.ExecutionInfo{QueryID: "q2", WorkGroup: "etl"})
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.inFlight.WithLabelValues("etl")))
+
+	m.OnFinish("q1", nil, succeeded)
+	m.OnPage("q1", 999)
+	m.OnPage("q1", 1)
+
+	failed := &athena.ExecutionInfo{QueryID: "q2", WorkGroup: "etl", State: types.QueryExecutionStateFailed, ErrorCategory: 2}
+	m.OnFinish("q2", context.DeadlineExceeded, failed)
+
+	m.OnStart("q3", "SELECT 3")
+	m.OnFinish("q3", athena.ErrScanBudgetExceeded, nil)
+
+	// Not started through the driver: ignored.
+	m.OnFinish("q4", nil, succeeded)
+
+	expected := `
+# HELP athena_queries_finished_total Number of queries finished, by outcome and error category.
+# TYPE athena_queries_finished_total counter
+athena_queries_finished_total{error_category="",outcome="succeeded",workgroup="etl"} 1
+athena_queries_finished_total{error_category="timeout",outcome="cancelled",workgroup="etl"} 1
+athena_queries_finished_total{error_category="scan_budget",outcome="cancelled",workgroup=""} 1
+# HELP athena_queries_in_flight Number of queries started and not finished yet.
+# TYPE athena_queries_in_flight gauge
+athena_queries_in_flight{workgroup="etl"} 0
+# HELP athena_queries_started_total Number of queries started.
+# TYPE athena_queries_started_total counter
+athena_queries_started_total{workgroup=""} 1
+athena_queries_started_total{workgroup="etl"} 2
+# HELP athena_query_admission_wait_seconds Time queries waited for the driver's limiter to let them run, by priority.
+# TYPE athena_query_admission_wait_seconds histogram
+athena_query_admission_wait_seconds_bucket{priority="high",le="1"} 0
+athena_query_admission_wait_seconds_bucket{priority="high",le="10"} 1
+athena_query_admission_wait_seconds_bucket{priority="high",le="+Inf"} 1
+athena_query_admission_wait_seconds_sum{priority="high"} 2
+athena_query_admission_wait_seconds_count{priority="high"} 1
+athena_query_admission_wait_seconds_bucket{priority="normal",le="1"} 1
+athena_query_admission_wait_seconds_bucket{priority="normal",le="10"} 1
+athena_query_admission_wait_seconds_bucket{priority="normal",le="+Inf"} 1
+athena_query_admission_wait_seconds_sum{priority="normal"} 0
+athena_query_admission_wait_seconds_count{priority="normal"} 1
+# HELP athena_query_execution_seconds Time queries spent executing in the Athena engine.
+# TYPE athena_query_execution_seconds histogram
+athena_query_execution_seconds_bucket{workgroup="etl",le="1"} 0
+athena_query_execution_seconds_bucket{workgroup="etl",le="10"} 1
+athena_query_execution_seconds_bucket{workgroup="etl",le="+Inf"} 1
+athena_query_execution_seconds_sum{workgroup="etl"} 5
+athena_query_execution_seconds_count{workgroup="etl"} 1
+# HELP athena_result_pages_total Number of pages of results fetched with GetQueryResults.
+# TYPE athena_result_pages_total counter
+athena_result_pages_total 2
+# HELP athena_result_rows_total Number of rows of results fetched.
+# TYPE athena_result_rows_total counter
+athena_result_rows_total 1000
+# HELP athena_scanned_bytes_total Bytes of data scanned by queries.
+# TYPE athena_scanned_bytes_total counter
+athena_scanned_bytes_total{workgroup="etl"} 1024
+`
+	require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected),
+		"athena_queries_finished_total",
+		"athena_queries_in_flight",
+		"athena_queries_started_total",
+		"athena_query_admission_wait_seconds",
+		"athena_query_execution_seconds",
+		"athena_result_pages_total",
+		"athena_result_rows_total",
+		"athena_scanned_bytes_total",
+	))
+	assert.Empty(t, m.queries)
+}
+
+func TestMetrics_QueryTTL(t *testing.T) {
+	m := New(Options{QueryTTL: time.Hour})
+	now := time.Unix(0, 0)
+	m.now = func() time.Time { return now }
+
+	// Started with Client.Start, and never waited on.
+	m.OnStart("q1", "SELECT 1")
+	m.OnStateChange("q1", types.QueryExecutionStateRunning, &athena.ExecutionInfo{QueryID: "q1", WorkGroup: "etl"})
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.inFlight.WithLabelValues("etl")))
+
+	now = now.Add(time.Hour)
+	m.OnStart("q2", "SELECT 2")
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight.WithLabelValues("etl")))
+	assert.NotContains(t, m.queries, athena.QueryID("q1"))
+
+	// Finishing a forgotten query doesn't count it again.
+	m.OnFinish("q1", nil, &athena.ExecutionInfo{QueryID: "q1", WorkGroup: "etl"})
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight.WithLabelValues("etl")))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.finished))
+}
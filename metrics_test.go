@@ -0,0 +1,40 @@
+package athena_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	athena "github.com/segmentio/go-athena"
+	"github.com/segmentio/go-athena/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_ScanEstimate(t *testing.T) {
+	m := metrics.New(metrics.Options{})
+	db, wait := athena.OpenFakeDB(t, athena.DriverConfig{
+		Hooks:              m,
+		CheckScanEstimates: true,
+		MaxBytesScanned:    10000,
+	})
+
+	_, err := db.ExecContext(context.Background(), "select")
+	require.NoError(t, err)
+	wait()
+
+	// The EXPLAIN checking the estimate isn't counted, and the query isn't in
+	// flight anymore.
+	expected := `
+# HELP athena_queries_in_flight Number of queries started and not finished yet.
+# TYPE athena_queries_in_flight gauge
+athena_queries_in_flight{workgroup=""} 0
+# HELP athena_queries_started_total Number of queries started.
+# TYPE athena_queries_started_total counter
+athena_queries_started_total{workgroup=""} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected),
+		"athena_queries_in_flight",
+		"athena_queries_started_total",
+	))
+}
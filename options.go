@@ -0,0 +1,130 @@
+package athena
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// ResultMode controls how the driver reads the rows of a query's results.
+type ResultMode int
+
+const (
+	// ResultModeDefault treats the first row of the results as a header and
+	// skips it, which is what Athena returns for SELECT queries.
+	ResultModeDefault ResultMode = iota
+
+	// ResultModeNoHeader returns every row of the results. Results of DDL
+	// statements such as SHOW PARTITIONS have no header row.
+	ResultModeNoHeader
+
+	// ResultModeAuto skips the header row only if Athena reports the query as
+	// a DML statement.
+	ResultModeAuto
+)
+
+// skipHeader reports whether the first row of qe's results is a header.
+func (m ResultMode) skipHeader(qe *types.QueryExecution) bool {
+	switch m {
+	case ResultModeNoHeader:
+		return false
+	case ResultModeAuto:
+		return qe == nil || qe.StatementType == "" || qe.StatementType == types.StatementTypeDml
+	default:
+		return true
+	}
+}
+
+// QueryOptions override the driver's configuration for a single query.
+// Zero values leave the corresponding setting unchanged.
+type QueryOptions struct {
+	Database  string
+	Catalog   string
+	WorkGroup string
+
+	// OutputLocation may be a template, like DriverConfig.OutputLocation.
+	OutputLocation string
+
+	// Encryption sets how Athena encrypts the query results in OutputLocation.
+	Encryption *types.EncryptionConfiguration
+
+	// ClientRequestToken makes StartQueryExecution idempotent: Athena returns
+	// the same execution for repeated requests carrying the same token.
+	ClientRequestToken string
+
+	ResultMode ResultMode
+
+	// Timeout bounds the time spent running the query and fetching the first
+	// page of its results. The query is stopped if it takes longer.
+	Timeout time.Duration
+
+	// MaxBytesScanned stops the query once it scanned more bytes than this,
+	// failing with a *ScanBudgetError. Negative values disable the limit set
+	// by DriverConfig.MaxBytesScanned.
+	MaxBytesScanned int64
+
+	// NoCache runs the query even if its results are in
+	// DriverConfig.ResultCache. The results are cached all the same.
+	NoCache bool
+
+	// PageSize is the number of rows fetched per page of results, up to
+	// 1000. See DriverConfig.PageSize.
+	PageSize int
+}
+
+// merge returns o with every non-zero field of override applied.
+func (o QueryOptions) merge(override QueryOptions) QueryOptions {
+	if override.Database != "" {
+		o.Database = override.Database
+	}
+	if override.Catalog != "" {
+		o.Catalog = override.Catalog
+	}
+	if override.WorkGroup != "" {
+		o.WorkGroup = override.WorkGroup
+	}
+	if override.OutputLocation != "" {
+		o.OutputLocation = override.OutputLocation
+	}
+	if override.Encryption != nil {
+		o.Encryption = override.Encryption
+	}
+	if override.ClientRequestToken != "" {
+		o.ClientRequestToken = override.ClientRequestToken
+	}
+	if override.ResultMode != ResultModeDefault {
+		o.ResultMode = override.ResultMode
+	}
+	if override.Timeout != 0 {
+		o.Timeout = override.Timeout
+	}
+	if override.MaxBytesScanned != 0 {
+		o.MaxBytesScanned = override.MaxBytesScanned
+	}
+	if override.NoCache {
+		o.NoCache = true
+	}
+	if override.PageSize != 0 {
+		o.PageSize = override.PageSize
+	}
+	return o
+}
+
+type queryOptionsKey struct{}
+
+// WithQueryOptions returns a context that overrides the driver's
+// configuration for queries run with it through `db.QueryContext` or
+// `db.ExecContext`. Options set by an outer WithQueryOptions call are kept
+// unless opts overrides them.
+func WithQueryOptions(ctx context.Context, opts QueryOptions) context.Context {
+	if outer, ok := queryOptionsFromContext(ctx); ok {
+		opts = outer.merge(opts)
+	}
+	return context.WithValue(ctx, queryOptionsKey{}, opts)
+}
+
+func queryOptionsFromContext(ctx context.Context) (QueryOptions, bool) {
+	opts, ok := ctx.Value(queryOptionsKey{}).(QueryOptions)
+	return opts, ok
+}
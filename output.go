@@ -0,0 +1,126 @@
+package athena
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// missingOutputValue is what placeholders of output locations expand to
+// when their value is missing. See DriverConfig.OutputLocation.
+const missingOutputValue = "none"
+
+// outputPlaceholders are the placeholders output locations may contain,
+// besides {label:<name>}.
+var outputPlaceholders = map[string]bool{
+	"workgroup": true,
+	"database":  true,
+	"catalog":   true,
+	"date":      true,
+	"year":      true,
+	"month":     true,
+	"day":       true,
+	"hour":      true,
+	"label":     true,
+}
+
+// validateOutputLocation checks the syntax of an output location template.
+func validateOutputLocation(location string) error {
+	_, err := expandOutputLocation(location, func(string) string { return "" })
+	return err
+}
+
+// outputLocation returns the output location of a query run with opts
+// through ctx at the given time, its template expanded.
+func outputLocation(ctx context.Context, opts QueryOptions, now time.Time) (string, error) {
+	now = now.UTC()
+	labels := labelsFromContext(ctx)
+
+	return expandOutputLocation(opts.OutputLocation, func(placeholder string) string {
+		switch placeholder {
+		case "workgroup":
+			return escapeOutputValue(orDefault(opts.WorkGroup, "primary"))
+		case "database":
+			return escapeOutputValue(opts.Database)
+		case "catalog":
+			return escapeOutputValue(catalogOrDefault(opts.Catalog))
+		case "date":
+			return now.Format(DateLayout)
+		case "year":
+			return now.Format("2006")
+		case "month":
+			return now.Format("01")
+		case "day":
+			return now.Format("02")
+		case "hour":
+			return now.Format("15")
+		case "label":
+			if len(labels) == 0 {
+				return missingOutputValue
+			}
+			names := make([]string, 0, len(labels))
+			for name := range labels {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			segments := make([]string, len(names))
+			for i, name := range names {
+				segments[i] = escapeOutputValue(name) + "=" + escapeOutputValue(labels[name])
+			}
+			return strings.Join(segments, "/")
+		default:
+			return escapeOutputValue(labels[strings.TrimPrefix(placeholder, "label:")])
+		}
+	})
+}
+
+// expandOutputLocation replaces the placeholders of location with the
+// values returned by value, failing on unknown placeholders.
+func expandOutputLocation(location string, value func(placeholder string) string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(location, '{')
+		end := strings.IndexByte(location, '}')
+		if start < 0 {
+			if end >= 0 {
+				return "", fmt.Errorf("output location: unexpected '}' in %q", location)
+			}
+			b.WriteString(location)
+			return b.String(), nil
+		}
+		if end < start {
+			return "", fmt.Errorf("output location: unterminated placeholder in %q", location)
+		}
+
+		placeholder := location[start+1 : end]
+		if !outputPlaceholders[placeholder] && !isLabelPlaceholder(placeholder) {
+			return "", fmt.Errorf("output location: unknown placeholder {%s}", placeholder)
+		}
+		b.WriteString(location[:start])
+		b.WriteString(value(placeholder))
+		location = location[end+1:]
+	}
+}
+
+func isLabelPlaceholder(placeholder string) bool {
+	name, ok := strings.CutPrefix(placeholder, "label:")
+	return ok && name != ""
+}
+
+// escapeOutputValue makes value fit for a path segment of an output location.
+func escapeOutputValue(value string) string {
+	if value == "" {
+		return missingOutputValue
+	}
+	return url.PathEscape(value)
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,80 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputLocation(t *testing.T) {
+	now := time.Date(2024, 3, 9, 17, 30, 0, 0, time.FixedZone("PST", -8*3600))
+	ctx := WithLabels(context.Background(), map[string]string{"team": "data/eng", "job": "daily"})
+
+	tests := []struct {
+		template string
+		opts     QueryOptions
+		expected string
+	}{
+		{
+			template: "s3://bucket/results/",
+			expected: "s3://bucket/results/",
+		},
+		{
+			template: "s3://bucket/{workgroup}/{database}/{catalog}/",
+			opts:     QueryOptions{Database: "db"},
+			expected: "s3://bucket/primary/db/AwsDataCatalog/",
+		},
+		{
+			template: "s3://bucket/{date}/{year}/{month}/{day}/{hour}/",
+			expected: "s3://bucket/2024-03-10/2024/03/10/01/",
+		},
+		{
+			template: "s3://bucket/{label:team}/{label:missing}/",
+			expected: "s3://bucket/data%2Feng/none/",
+		},
+		{
+			template: "s3://bucket/{label}/",
+			expected: "s3://bucket/job=daily/team=data%2Feng/",
+		},
+	}
+
+	for _, test := range tests {
+		opts := test.opts
+		opts.OutputLocation = test.template
+		location, err := outputLocation(ctx, opts, now)
+		require.NoError(t, err, test.template)
+		assert.Equal(t, test.expected, location, test.template)
+	}
+}
+
+func TestValidateOutputLocation(t *testing.T) {
+	assert.NoError(t, validateOutputLocation("s3://bucket/{workgroup}/{label:team}/"))
+	assert.EqualError(t, validateOutputLocation("s3://bucket/{query}/"), "output location: unknown placeholder {query}")
+	assert.Error(t, validateOutputLocation("s3://bucket/{label:}/"))
+	assert.Error(t, validateOutputLocation("s3://bucket/{date/"))
+	assert.Error(t, validateOutputLocation("s3://bucket/date}/"))
+
+	_, err := NewConnector(DriverConfig{Config: &aws.Config{}, OutputLocation: "s3://bucket/{nope}/"})
+	assert.Error(t, err)
+}
+
+func TestConn_OutputLocationTemplate(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		OutputLocation: "s3://bucket/{workgroup}/{label:team}/",
+		WorkGroup:      "etl",
+	}))
+
+	ctx := WithLabels(context.Background(), map[string]string{"team": "growth"})
+	_, err := db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+	assert.Equal(t, "s3://bucket/etl/growth/", aws.ToString(fake.started[0].ResultConfiguration.OutputLocation))
+
+	ctx = WithQueryOptions(ctx, QueryOptions{OutputLocation: "s3://bucket/{unknown}/"})
+	_, err = db.ExecContext(ctx, "select")
+	assert.ErrorContains(t, err, "unknown placeholder")
+}
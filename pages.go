@@ -0,0 +1,58 @@
+package athena
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPageSize is the largest number of rows GetQueryResults returns per
+// page, which is also its default.
+const maxPageSize = 1000
+
+func validatePageSize(pageSize int) error {
+	if pageSize < 0 || pageSize > maxPageSize {
+		return fmt.Errorf("page size must be between 0 and %d, got %d", maxPageSize, pageSize)
+	}
+	return nil
+}
+
+// limitPattern matches a LIMIT clause ending a query.
+var limitPattern = regexp.MustCompile(`(?i)\blimit\s+(\d+)$`)
+
+// queryLimit returns the number of rows of the LIMIT clause ending query, if
+// any. Queries with comments are ignored, as the clause could be commented
+// out.
+func queryLimit(query string) (int, bool) {
+	if strings.Contains(query, "--") || strings.Contains(query, "/*") {
+		return 0, false
+	}
+
+	m := limitPattern.FindStringSubmatch(normalizeQuery(query))
+	if m == nil {
+		return 0, false
+	}
+	limit, err := strconv.Atoi(m[1])
+	if err != nil || limit <= 0 {
+		return 0, false
+	}
+	return limit, true
+}
+
+// pageRequestSize returns the number of rows to request with the next call
+// to GetQueryResults, or zero for Athena's default. header is whether the
+// page starts with a header row, which counts as a row.
+func (r *rows) pageRequestSize(header bool) int {
+	size := r.pageSize
+	if r.limit > 0 {
+		remaining := r.limit - r.read
+		if header {
+			remaining++
+		}
+		if size == 0 || remaining < size {
+			size = min(remaining, maxPageSize)
+		}
+	}
+	return size
+}
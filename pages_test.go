@@ -0,0 +1,106 @@
+package athena
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	queryToResultsGenMap["select * from t limit 3"] = dummySelectQueryResponse
+	queryToResultsGenMap["select * from t limit 7"] = dummySelectQueryResponse
+	queryToResultsGenMap["SELECT * FROM t\n LIMIT 7;"] = dummySelectQueryResponse
+}
+
+// requestedPageSizes returns the MaxResults of the GetQueryResults calls
+// made, zero meaning unset.
+func (f *fakeAthena) requestedPageSizes() []int32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var sizes []int32
+	for _, in := range f.results {
+		sizes = append(sizes, aws.ToInt32(in.MaxResults))
+	}
+	return sizes
+}
+
+func TestConn_PageSize(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{PageSize: 5}))
+
+	assert.Len(t, readAll(t, db, context.Background(), "select"), 9)
+	assert.Equal(t, []int32{5, 5}, fake.requestedPageSizes())
+
+	ctx := WithQueryOptions(context.Background(), QueryOptions{PageSize: 1000})
+	readAll(t, db, ctx, "select")
+	assert.Equal(t, []int32{5, 5, 1000, 1000}, fake.requestedPageSizes())
+
+	ctx = WithQueryOptions(context.Background(), QueryOptions{PageSize: 1001})
+	_, err := db.QueryContext(ctx, "select")
+	assert.EqualError(t, err, "page size must be between 0 and 1000, got 1001")
+
+	_, err = NewConnector(DriverConfig{Config: &aws.Config{}, PageSize: -1})
+	assert.EqualError(t, err, "page size must be between 0 and 1000, got -1")
+}
+
+func TestConn_PageSizeDefault(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestDB(t, fake)
+
+	readAll(t, db, context.Background(), "select")
+	assert.Equal(t, []int32{0, 0}, fake.requestedPageSizes())
+}
+
+func TestConn_Limit(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestDB(t, fake)
+
+	// The first page is enough, counting its header row.
+	assert.Len(t, readAll(t, db, context.Background(), "select * from t limit 3"), 3)
+	assert.Equal(t, []int32{4}, fake.requestedPageSizes())
+
+	fake.results = nil
+	assert.Len(t, readAll(t, db, context.Background(), "SELECT * FROM t\n LIMIT 7;"), 7)
+	assert.Equal(t, []int32{8, 3}, fake.requestedPageSizes())
+}
+
+func TestConn_LimitPageSize(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{PageSize: 2}))
+
+	rows, err := db.QueryContext(context.Background(), "select * from t limit 7")
+	require.NoError(t, err)
+	defer rows.Close()
+	assert.Equal(t, []int32{2}, fake.requestedPageSizes())
+}
+
+func TestQueryLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		limit int
+		ok    bool
+	}{
+		{"SELECT * FROM t LIMIT 10", 10, true},
+		{"select * from t\nlimit   5 ;", 5, true},
+		{"SELECT * FROM t", 0, false},
+		{"SELECT * FROM (SELECT * FROM t LIMIT 10)", 0, false},
+		{"SELECT * FROM (SELECT * FROM t LIMIT 10) LIMIT 5", 5, true},
+		{"SELECT * FROM t WHERE a IN (SELECT a FROM u LIMIT 10)", 0, false},
+		{"WITH x AS (SELECT * FROM t LIMIT 10) SELECT * FROM x", 0, false},
+		{"SELECT * FROM t WHERE s = 'LIMIT 10'", 0, false},
+		{"SELECT * FROM t WHERE s = 'a--b' LIMIT 10", 0, false},
+		{"SELECT * FROM t LIMIT 0", 0, false},
+		{"SELECT * FROM t LIMIT ALL", 0, false},
+		{"SELECT * FROM t -- LIMIT 10", 0, false},
+		{"SELECT nolimit 10", 0, false},
+	}
+	for _, test := range tests {
+		limit, ok := queryLimit(test.query)
+		assert.Equal(t, test.limit, limit, test.query)
+		assert.Equal(t, test.ok, ok, test.query)
+	}
+}
@@ -0,0 +1,44 @@
+package athena
+
+import (
+	"context"
+	"strconv"
+)
+
+// Priority orders the queries waiting for a Limiter to admit them: queries
+// with a higher priority run first.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// String returns "low", "normal" or "high", or the number for other values.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return strconv.Itoa(int(p))
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context setting the priority of the queries run
+// with it, e.g. PriorityHigh for interactive queries and PriorityLow for
+// batch jobs. Queries default to PriorityNormal. See Limiter.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityKey{}).(Priority)
+	return priority
+}
@@ -0,0 +1,116 @@
+package athena
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// Progress describes how far a running query got, e.g. to render a progress
+// bar. It's reported on every poll of the query. See WithProgress.
+type Progress struct {
+	QueryID            QueryID
+	State              types.QueryExecutionState
+	Elapsed            time.Duration
+	DataScannedInBytes int64
+
+	// Stages lists the stages of the query known so far, as reported by
+	// GetQueryRuntimeStatistics. It's empty until the query runs, and if the
+	// statistics aren't available.
+	Stages []StageProgress
+}
+
+// StageProgress describes a stage of a running query.
+type StageProgress struct {
+	StageID int64
+
+	// State is the state of the stage in the engine, e.g. "RUNNING" or "FINISHED".
+	State string
+
+	InputRows   int64
+	InputBytes  int64
+	OutputRows  int64
+	OutputBytes int64
+}
+
+// StagesFinished returns the number of finished stages, and the number of
+// stages.
+func (p Progress) StagesFinished() (finished, total int) {
+	for _, stage := range p.Stages {
+		if stage.State == "FINISHED" {
+			finished++
+		}
+	}
+	return finished, len(p.Stages)
+}
+
+// ProgressFunc receives the progress of a query. It's called synchronously
+// from the polling loop, so it should return quickly.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes queries run with it report their
+// progress to fn, instead of DriverConfig.Progress.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFunc returns the ProgressFunc for queries run with ctx, if any.
+func (c *conn) progressFunc(ctx context.Context) ProgressFunc {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		return fn
+	}
+	return c.progress
+}
+
+// reportProgress reports the progress of qe to fn, fetching the statistics of
+// its stages if it's running.
+func (c *conn) reportProgress(ctx context.Context, fn ProgressFunc, qe *types.QueryExecution, polledSince time.Time) {
+	progress := Progress{
+		QueryID:            QueryID(aws.ToString(qe.QueryExecutionId)),
+		State:              qe.Status.State,
+		DataScannedInBytes: dataScannedInBytes(qe),
+	}
+
+	if submitted := aws.ToTime(qe.Status.SubmissionDateTime); !submitted.IsZero() {
+		progress.Elapsed = time.Since(submitted)
+	} else {
+		progress.Elapsed = time.Since(polledSince)
+	}
+
+	if qe.Status.State == types.QueryExecutionStateRunning {
+		resp, err := c.athena.GetQueryRuntimeStatistics(ctx, &athena.GetQueryRuntimeStatisticsInput{
+			QueryExecutionId: qe.QueryExecutionId,
+		})
+		// Statistics are best effort: they may not be available yet.
+		if err == nil && resp.QueryRuntimeStatistics != nil {
+			progress.Stages = flattenStages(resp.QueryRuntimeStatistics.OutputStage, nil)
+		}
+	}
+
+	fn(progress)
+}
+
+// flattenStages appends stage and its sub-stages to stages.
+func flattenStages(stage *types.QueryStage, stages []StageProgress) []StageProgress {
+	if stage == nil {
+		return stages
+	}
+
+	stages = append(stages, StageProgress{
+		StageID:     aws.ToInt64(stage.StageId),
+		State:       aws.ToString(stage.State),
+		InputRows:   aws.ToInt64(stage.InputRows),
+		InputBytes:  aws.ToInt64(stage.InputBytes),
+		OutputRows:  aws.ToInt64(stage.OutputRows),
+		OutputBytes: aws.ToInt64(stage.OutputBytes),
+	})
+	for i := range stage.SubStages {
+		stages = flattenStages(&stage.SubStages[i], stages)
+	}
+	return stages
+}
@@ -0,0 +1,41 @@
+package athena
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	var reported []Progress
+	fake := newFakeAthena(types.QueryExecutionStateQueued, types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		Progress: func(Progress) { t.Error("the context's progress func must be used") },
+	}))
+
+	ctx := WithProgress(context.Background(), func(p Progress) {
+		reported = append(reported, p)
+	})
+	_, err := db.ExecContext(ctx, "select")
+	require.NoError(t, err)
+
+	require.Len(t, reported, 3)
+	assert.Equal(t, types.QueryExecutionStateQueued, reported[0].State)
+	assert.Empty(t, reported[0].Stages)
+
+	running := reported[1]
+	assert.Equal(t, QueryID("query-1"), running.QueryID)
+	assert.Equal(t, types.QueryExecutionStateRunning, running.State)
+	assert.Equal(t, int64(2048), running.DataScannedInBytes)
+	assert.Positive(t, running.Elapsed)
+	require.Len(t, running.Stages, 2)
+	assert.Equal(t, StageProgress{StageID: 1, State: "FINISHED", InputRows: 100, InputBytes: 2048, OutputRows: 9}, running.Stages[1])
+	finished, total := running.StagesFinished()
+	assert.Equal(t, 1, finished)
+	assert.Equal(t, 2, total)
+
+	assert.Equal(t, types.QueryExecutionStateSucceeded, reported[2].State)
+}
@@ -0,0 +1,166 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// pageRows are driver rows that can also be read a page at a time.
+type pageRows interface {
+	driver.Rows
+
+	columnInfo() []types.ColumnInfo
+
+	// nextPage returns the rows not read yet of the next page of results, or
+	// io.EOF at their end.
+	nextPage() ([]types.Row, error)
+}
+
+var (
+	_ pageRows = (*rows)(nil)
+	_ pageRows = (*cachedRows)(nil)
+)
+
+// QueryArrow runs query on db, which must be an Athena database, and returns
+// a reader of its results as Arrow records, one per page of results. The
+// records are built from the text of the values, without converting them
+// to Go values first. Records are allocated with mem, or
+// memory.DefaultAllocator if it's nil.
+//
+// Athena types map to the Arrow types of their values, with decimals as
+// Decimal128 and timestamps in milliseconds. Athena doesn't report the
+// types of the elements of arrays and maps, so arrays are lists of strings,
+// and maps map strings to strings. Rows, and types with no Arrow equivalent,
+// are strings.
+//
+// The reader must be released once done with, which closes the results.
+func QueryArrow(ctx context.Context, db *sql.DB, query string, mem memory.Allocator) (array.RecordReader, error) {
+	if mem == nil {
+		mem = memory.DefaultAllocator
+	}
+
+	sqlConn, results, err := queryPages(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := recordSchema(results.columnInfo())
+	return &recordReader{
+		refs:    1,
+		schema:  schema,
+		conn:    sqlConn,
+		rows:    results,
+		builder: newRecordBuilder(mem, schema),
+	}, nil
+}
+
+// queryPages runs query on a connection of db, which must be an Athena
+// database, returning its results to be read a page at a time. The
+// connection is reserved to the results until they're closed, after which
+// the caller must close it.
+func queryPages(ctx context.Context, db *sql.DB, query string) (*sql.Conn, pageRows, error) {
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results pageRows
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		cn, ok := driverConn.(*conn)
+		if !ok {
+			return errors.New("athena: the DB must be opened with the athena driver")
+		}
+
+		dr, err := cn.QueryContext(ctx, query, nil)
+		if err != nil {
+			return err
+		}
+		results = dr.(pageRows)
+		return nil
+	})
+	if err != nil {
+		sqlConn.Close()
+		return nil, nil, err
+	}
+	return sqlConn, results, nil
+}
+
+// recordReader is an array.RecordReader of query results.
+type recordReader struct {
+	refs    int64
+	schema  *arrow.Schema
+	conn    *sql.Conn
+	rows    pageRows
+	builder *recordBuilder
+	cur     arrow.Record
+	err     error
+}
+
+func (r *recordReader) Retain() {
+	atomic.AddInt64(&r.refs, 1)
+}
+
+func (r *recordReader) Release() {
+	if atomic.AddInt64(&r.refs, -1) != 0 {
+		return
+	}
+
+	if r.cur != nil {
+		r.cur.Release()
+		r.cur = nil
+	}
+	r.builder.release()
+	r.rows.Close()
+	r.conn.Close()
+}
+
+func (r *recordReader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+func (r *recordReader) Next() bool {
+	if r.cur != nil {
+		r.cur.Release()
+		r.cur = nil
+	}
+	if r.err != nil {
+		return false
+	}
+
+	page, err := r.rows.nextPage()
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		return false
+	}
+	for _, row := range page {
+		if err := r.builder.appendRow(row); err != nil {
+			// Drop the rows appended so far.
+			r.builder.newRecord().Release()
+			r.err = err
+			return false
+		}
+	}
+	r.cur = r.builder.newRecord()
+	return true
+}
+
+// Record returns the current record. It's only valid until the next call to
+// Next.
+func (r *recordReader) Record() arrow.Record {
+	return r.cur
+}
+
+func (r *recordReader) Err() error {
+	return r.err
+}
@@ -0,0 +1,154 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	queryToResultsGenMap["select complex"] = dummyComplexResponse
+}
+
+// dummyComplexResponse returns results with decimal and complex columns.
+func dummyComplexResponse(_ string) (*athena.GetQueryResultsOutput, error) {
+	column := func(name, athenaType string) types.ColumnInfo {
+		col := genColumnInfo(name)
+		col.Type = aws.String(athenaType)
+		return col
+	}
+	price := column("price", "decimal")
+	price.Precision, price.Scale = 10, 2
+	columns := []types.ColumnInfo{
+		price,
+		column("tags", "array"),
+		column("attrs", "map"),
+		column("point", "row"),
+	}
+	datum := func(v *string) types.Datum { return types.Datum{VarCharValue: v} }
+	return &athena.GetQueryResultsOutput{
+		ResultSet: &types.ResultSet{
+			ResultSetMetadata: &types.ResultSetMetadata{ColumnInfo: columns},
+			Rows: []types.Row{
+				{Data: []types.Datum{datum(aws.String("price")), datum(aws.String("tags")), datum(aws.String("attrs")), datum(aws.String("point"))}},
+				{Data: []types.Datum{datum(aws.String("12.34")), datum(aws.String("[a, null]")), datum(aws.String("{k=v, n=null}")), datum(aws.String("{x=1, y=2}"))}},
+				{Data: []types.Datum{datum(nil), datum(aws.String("[]")), datum(nil), datum(nil)}},
+			},
+		},
+	}, nil
+}
+
+func TestQueryArrow(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	reader, err := QueryArrow(context.Background(), db, "select", mem)
+	require.NoError(t, err)
+	defer reader.Release()
+
+	assert.Equal(t, "first_name", reader.Schema().Field(0).Name)
+	assert.Equal(t, arrow.BinaryTypes.String, reader.Schema().Field(0).Type)
+
+	// A record per page of results.
+	var counts []int64
+	for reader.Next() {
+		counts = append(counts, reader.Record().NumRows())
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, []int64{4, 5}, counts)
+}
+
+func TestQueryArrow_HoldsConn(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+	db.SetMaxOpenConns(1)
+
+	reader, err := QueryArrow(context.Background(), db, "select", nil)
+	require.NoError(t, err)
+
+	// The connection is reserved to the reader until it's released.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.Conn(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	reader.Release()
+	sqlConn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, sqlConn.Close())
+}
+
+func TestQueryArrow_Types(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+	mem := memory.NewCheckedAllocator(memory.DefaultAllocator)
+	defer mem.AssertSize(t, 0)
+
+	reader, err := QueryArrow(context.Background(), db, "typed", mem)
+	require.NoError(t, err)
+	require.True(t, reader.Next())
+	rec := reader.Record()
+	require.EqualValues(t, 2, rec.NumRows())
+	assert.Equal(t, []int64{1, 2}, rec.Column(0).(*array.Int64).Int64Values())
+	assert.True(t, rec.Column(1).IsNull(1))
+	assert.Equal(t, 1.5, rec.Column(2).(*array.Float64).Value(0))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), rec.Column(4).(*array.Date32).Value(0).ToTime())
+	assert.Equal(t, arrow.Timestamp(1709296200250), rec.Column(5).(*array.Timestamp).Value(0))
+	assert.False(t, reader.Next())
+	reader.Release()
+
+	reader, err = QueryArrow(context.Background(), db, "select complex", mem)
+	require.NoError(t, err)
+	defer reader.Release()
+	schema := reader.Schema()
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, schema.Field(0).Type)
+	assert.Equal(t, arrow.ListOf(arrow.BinaryTypes.String), schema.Field(1).Type)
+	assert.Equal(t, arrow.MapOf(arrow.BinaryTypes.String, arrow.BinaryTypes.String), schema.Field(2).Type)
+	assert.Equal(t, arrow.BinaryTypes.String, schema.Field(3).Type)
+	typ, _ := schema.Field(3).Metadata.GetValue("athena.type")
+	assert.Equal(t, "row", typ)
+
+	require.True(t, reader.Next())
+	rec = reader.Record()
+	assert.Equal(t, "12.34", rec.Column(0).ValueStr(0))
+	assert.True(t, rec.Column(0).IsNull(1))
+	assert.Equal(t, `["a",null]`, rec.Column(1).(*array.List).ValueStr(0))
+	assert.Equal(t, "[]", rec.Column(1).(*array.List).ValueStr(1))
+	attrs := rec.Column(2).(*array.Map)
+	assert.Equal(t, []string{"k", "n"}, []string{attrs.Keys().ValueStr(0), attrs.Keys().ValueStr(1)})
+	assert.True(t, attrs.Items().IsNull(1))
+	assert.Equal(t, "{x=1, y=2}", rec.Column(3).ValueStr(0))
+}
+
+func TestQueryArrow_Cached(t *testing.T) {
+	fake := newFakeAthena()
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		ResultCache: NewMemoryCache(time.Hour, 1<<20),
+	}))
+
+	reader, err := QueryArrow(context.Background(), db, "select complex", nil)
+	require.NoError(t, err)
+	for reader.Next() {
+	}
+	require.NoError(t, reader.Err())
+	reader.Release()
+
+	reader, err = QueryArrow(context.Background(), db, "select complex", nil)
+	require.NoError(t, err)
+	defer reader.Release()
+	assert.Len(t, fake.started, 1)
+
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, reader.Schema().Field(0).Type)
+	require.True(t, reader.Next())
+	assert.EqualValues(t, 2, reader.Record().NumRows())
+	assert.False(t, reader.Next())
+	require.NoError(t, reader.Err())
+}
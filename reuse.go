@@ -0,0 +1,60 @@
+package athena
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// maxResultReuseAge is the oldest result Athena is willing to reuse.
+const maxResultReuseAge = 7 * 24 * time.Hour
+
+// ResultReuseConfig controls whether Athena may answer a query with the
+// results of an identical recent query instead of running it again.
+type ResultReuseConfig struct {
+	Enabled bool
+
+	// MaxAge is the maximum age of a result Athena may reuse. It's rounded up
+	// to the minute. If zero, Athena's default of 60 minutes applies.
+	MaxAge time.Duration
+}
+
+func (r ResultReuseConfig) validate() error {
+	if r.MaxAge < 0 || r.MaxAge > maxResultReuseAge {
+		return fmt.Errorf("result reuse max age must be between 0 and %s, got %s", maxResultReuseAge, r.MaxAge)
+	}
+	return nil
+}
+
+// apiConfig returns the configuration to send with StartQueryExecution, or
+// nil if result reuse is disabled.
+func (r ResultReuseConfig) apiConfig() *types.ResultReuseConfiguration {
+	if !r.Enabled {
+		return nil
+	}
+
+	byAge := &types.ResultReuseByAgeConfiguration{Enabled: true}
+	if r.MaxAge > 0 {
+		minutes := math.Ceil(r.MaxAge.Minutes())
+		byAge.MaxAgeInMinutes = aws.Int32(int32(minutes))
+	}
+
+	return &types.ResultReuseConfiguration{ResultReuseByAgeConfiguration: byAge}
+}
+
+type resultReuseKey struct{}
+
+// WithResultReuse returns a context that overrides the driver's result reuse
+// configuration for queries run with it.
+func WithResultReuse(ctx context.Context, cfg ResultReuseConfig) context.Context {
+	return context.WithValue(ctx, resultReuseKey{}, cfg)
+}
+
+func resultReuseFromContext(ctx context.Context) (ResultReuseConfig, bool) {
+	cfg, ok := ctx.Value(resultReuseKey{}).(ResultReuseConfig)
+	return cfg, ok
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql/driver"
 	"io"
+	"log/slog"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type rows struct {
@@ -16,12 +19,40 @@ type rows struct {
 	done          bool
 	skipHeaderRow bool
 	out           *athena.GetQueryResultsOutput
+
+	tracer  trace.Tracer
+	spanCtx trace.SpanContext
+	page    int
+	hooks   *hookDispatcher
+	logger  *slog.Logger
+
+	// cache, if set, collects the rows read to cache them.
+	cache *cacheWriter
+
+	// cleanup is called once the rows are closed.
+	cleanup func()
+
+	// pageSize is the number of rows requested per page, zero meaning
+	// Athena's default.
+	pageSize int
+
+	// limit, if positive, is the number of rows of the query's LIMIT clause.
+	// No more pages are fetched once that many rows were read.
+	limit int
+	read  int
 }
 
 type rowsConfig struct {
 	Athena     athenaAPI
 	QueryID    string
 	SkipHeader bool
+	Tracer     trace.Tracer
+	Hooks      *hookDispatcher
+	Logger     *slog.Logger
+	Cache      *cacheWriter
+	Cleanup    func()
+	PageSize   int
+	Limit      int
 }
 
 func newRows(ctx context.Context, cfg rowsConfig) (*rows, error) {
@@ -29,10 +60,26 @@ func newRows(ctx context.Context, cfg rowsConfig) (*rows, error) {
 		athena:        cfg.Athena,
 		queryID:       cfg.QueryID,
 		skipHeaderRow: cfg.SkipHeader,
+		tracer:        cfg.Tracer,
+		hooks:         cfg.Hooks,
+		logger:        cfg.Logger,
+		cache:         cfg.Cache,
+		cleanup:       cfg.Cleanup,
+		pageSize:      cfg.PageSize,
+		limit:         cfg.Limit,
+		// Pages fetched by Next are traced as children of the query's span.
+		spanCtx: trace.SpanContextFromContext(ctx),
+	}
+	if r.tracer == nil {
+		r.tracer = newTracer(nil)
+	}
+	if r.logger == nil {
+		r.logger = newLogger(nil)
 	}
 
 	shouldContinue, err := r.fetchNextPage(ctx, nil)
 	if err != nil {
+		r.Close()
 		return nil, err
 	}
 
@@ -58,6 +105,41 @@ func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
 }
 
 func (r *rows) Next(dest []driver.Value) error {
+	if err := r.fill(); err != nil {
+		return err
+	}
+
+	// Shift to next row
+	cur := r.out.ResultSet.Rows[0]
+	columns := r.out.ResultSet.ResultSetMetadata.ColumnInfo
+	if err := convertRow(columns, cur.Data, dest); err != nil {
+		return err
+	}
+
+	r.out.ResultSet.Rows = r.out.ResultSet.Rows[1:]
+	return nil
+}
+
+// columnInfo returns the columns of the results.
+func (r *rows) columnInfo() []types.ColumnInfo {
+	return r.out.ResultSet.ResultSetMetadata.ColumnInfo
+}
+
+// nextPage returns the rows of the current page that weren't read yet, and
+// moves on to the next page. It returns io.EOF once all rows were read.
+func (r *rows) nextPage() ([]types.Row, error) {
+	if err := r.fill(); err != nil {
+		return nil, err
+	}
+
+	page := r.out.ResultSet.Rows
+	r.out.ResultSet.Rows = nil
+	return page, nil
+}
+
+// fill fetches the next page of results once the rows of the current one
+// were all read. It returns io.EOF if there are no rows left.
+func (r *rows) fill() error {
 	if r.done {
 		return io.EOF
 	}
@@ -65,13 +147,15 @@ func (r *rows) Next(dest []driver.Value) error {
 	// If nothing left to iterate...
 	if len(r.out.ResultSet.Rows) == 0 {
 		// And if nothing more to paginate...
-		if r.out.NextToken == nil || *r.out.NextToken == "" {
+		if r.out.NextToken == nil || *r.out.NextToken == "" || (r.limit > 0 && r.read >= r.limit) {
+			r.cacheResults()
 			return io.EOF
 		}
 
 		// A context cannot be passed into the Next function because it is defined
 		// in the database.sql.driver.Rows interface.
-		cont, err := r.fetchNextPage(context.Background(), r.out.NextToken)
+		ctx := trace.ContextWithSpanContext(context.Background(), r.spanCtx)
+		cont, err := r.fetchNextPage(ctx, r.out.NextToken)
 		if err != nil {
 			return err
 		}
@@ -80,27 +164,35 @@ func (r *rows) Next(dest []driver.Value) error {
 			return io.EOF
 		}
 	}
-
-	// Shift to next row
-	cur := r.out.ResultSet.Rows[0]
-	columns := r.out.ResultSet.ResultSetMetadata.ColumnInfo
-	if err := convertRow(columns, cur.Data, dest); err != nil {
-		return err
-	}
-
-	r.out.ResultSet.Rows = r.out.ResultSet.Rows[1:]
 	return nil
 }
 
-func (r *rows) fetchNextPage(ctx context.Context, token *string) (bool, error) {
-	var err error
-	r.out, err = r.athena.GetQueryResults(ctx, &athena.GetQueryResultsInput{
+func (r *rows) fetchNextPage(ctx context.Context, token *string) (_ bool, err error) {
+	r.page++
+	ctx, span := r.tracer.Start(ctx, "athena.GetQueryResults",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrQueryID.String(r.queryID), attrPage.Int(r.page)),
+	)
+	defer func() { endSpan(span, err) }()
+
+	in := &athena.GetQueryResultsInput{
 		QueryExecutionId: aws.String(r.queryID),
 		NextToken:        token,
-	})
+	}
+	if size := r.pageRequestSize(r.skipHeaderRow); size > 0 {
+		in.MaxResults = aws.Int32(int32(size))
+	}
+	r.out, err = r.athena.GetQueryResults(ctx, in)
 	if err != nil {
 		return false, err
 	}
+	span.SetAttributes(attrPageRows.Int(len(r.out.ResultSet.Rows)))
+	r.logger.LogAttrs(ctx, slog.LevelDebug, "athena: fetched results page",
+		slog.String("query_id", r.queryID),
+		slog.Int("page", r.page),
+		slog.Int("rows", len(r.out.ResultSet.Rows)),
+		slog.Bool("last", aws.ToString(r.out.NextToken) == ""),
+	)
 
 	var rowOffset = 0
 	// First row of the first page contains header if the query is not DDL.
@@ -111,14 +203,41 @@ func (r *rows) fetchNextPage(ctx context.Context, token *string) (bool, error) {
 	}
 
 	if len(r.out.ResultSet.Rows) < rowOffset+1 {
+		r.hooks.onPage(r.queryID, 0)
+		if r.cache != nil {
+			// Record the columns of empty results.
+			r.cache.page(r.out.ResultSet.ResultSetMetadata.ColumnInfo, nil)
+		}
+		r.cacheResults()
 		return false, nil
 	}
 
 	r.out.ResultSet.Rows = r.out.ResultSet.Rows[rowOffset:]
+	if r.limit > 0 {
+		r.out.ResultSet.Rows = r.out.ResultSet.Rows[:min(len(r.out.ResultSet.Rows), r.limit-r.read)]
+		r.read += len(r.out.ResultSet.Rows)
+	}
+	r.hooks.onPage(r.queryID, len(r.out.ResultSet.Rows))
+	if r.cache != nil && !r.cache.page(r.out.ResultSet.ResultSetMetadata.ColumnInfo, r.out.ResultSet.Rows) {
+		r.cache = nil
+	}
 	return true, nil
 }
 
+// cacheResults caches the rows read, once the results were read to the end.
+func (r *rows) cacheResults() {
+	if r.cache != nil {
+		r.cache.done()
+		r.cache = nil
+	}
+}
+
 func (r *rows) Close() error {
 	r.done = true
+	r.cache = nil
+	if r.cleanup != nil {
+		r.cleanup()
+		r.cleanup = nil
+	}
 	return nil
 }
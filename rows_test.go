@@ -2,6 +2,7 @@ package athena
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/athena"
 	"github.com/aws/aws-sdk-go-v2/service/athena/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var dummyError = errors.New("dummy error")
@@ -237,3 +239,31 @@ func TestRows_Next(t *testing.T) {
 		}
 	}
 }
+
+func TestRows_ScanComplex(t *testing.T) {
+	db := openTestDB(t, newFakeAthena())
+
+	rows, err := db.QueryContext(context.Background(), "select complex")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	// Arrays, maps and rows are returned as the text Athena returns for them.
+	var price sql.NullFloat64
+	var tags, attrs, point sql.NullString
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&price, &tags, &attrs, &point))
+	assert.Equal(t, sql.NullFloat64{Float64: 12.34, Valid: true}, price)
+	assert.Equal(t, sql.NullString{String: "[a, null]", Valid: true}, tags)
+	assert.Equal(t, sql.NullString{String: "{k=v, n=null}", Valid: true}, attrs)
+	assert.Equal(t, sql.NullString{String: "{x=1, y=2}", Valid: true}, point)
+
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&price, &tags, &attrs, &point))
+	assert.False(t, price.Valid)
+	assert.Equal(t, sql.NullString{String: "[]", Valid: true}, tags)
+	assert.False(t, attrs.Valid)
+	assert.False(t, point.Valid)
+
+	require.False(t, rows.Next())
+	require.NoError(t, rows.Err())
+}
@@ -0,0 +1,139 @@
+package athena
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShutdown is returned for queries stopped or refused because their
+// Connector was shut down. It's also the reason reported to Hooks.OnCancel
+// for the queries stopped.
+var ErrShutdown = errors.New("athena: connector shut down")
+
+// defaultShutdownTimeout is the default of DriverConfig.ShutdownTimeout.
+const defaultShutdownTimeout = 30 * time.Second
+
+// inFlightQueries tracks the queries started by the connections of a
+// Connector, until the driver is done waiting on them. A nil
+// *inFlightQueries tracks nothing.
+type inFlightQueries struct {
+	mu      sync.Mutex
+	queries map[string]*conn
+	closed  bool
+}
+
+func newInFlightQueries() *inFlightQueries {
+	return &inFlightQueries{queries: map[string]*conn{}}
+}
+
+// isClosed reports whether the connector was shut down.
+func (q *inFlightQueries) isClosed() bool {
+	if q == nil {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// add tracks a query started by c. It returns false, not tracking the query,
+// if the connector was shut down.
+func (q *inFlightQueries) add(queryID string, c *conn) bool {
+	if q == nil {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.queries[queryID] = c
+	return true
+}
+
+// remove stops tracking a query. It returns false if the query was taken
+// by a shutdown in the meantime.
+func (q *inFlightQueries) remove(queryID string) bool {
+	if q == nil {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.queries[queryID]; !ok {
+		return false
+	}
+	delete(q.queries, queryID)
+	return true
+}
+
+// take stops tracking the queries of c, or of every connection if c is nil,
+// and returns them. If close is set, later calls to add fail.
+func (q *inFlightQueries) take(c *conn, close bool) map[string]*conn {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if close {
+		q.closed = true
+	}
+
+	taken := map[string]*conn{}
+	for queryID, owner := range q.queries {
+		if c == nil || owner == c || owner.parent == c {
+			taken[queryID] = owner
+			delete(q.queries, queryID)
+		}
+	}
+	return taken
+}
+
+// stopQueries stops queries concurrently, returning once they're all stopped
+// or ctx is done.
+func stopQueries(ctx context.Context, queries map[string]*conn) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(queries))
+	for queryID, c := range queries {
+		wg.Add(1)
+		go func(queryID string, c *conn) {
+			defer wg.Done()
+			errs <- c.stopQuery(ctx, queryID, ErrShutdown)
+		}(queryID, c)
+	}
+	wg.Wait()
+	close(errs)
+
+	var all []error
+	for err := range errs {
+		all = append(all, err)
+	}
+	return errors.Join(all...)
+}
+
+// Shutdown stops the queries the connections of the connector are waiting
+// on, which then fail with ErrShutdown, as do queries run afterwards, and
+// deletes the results waiting to be cleaned up. It returns once done or
+// once ctx is done.
+//
+// Queries started with Client.Start are left running, as they're meant to
+// outlive the process starting them.
+func (c *Connector) Shutdown(ctx context.Context) error {
+	return errors.Join(
+		stopQueries(ctx, c.queries.take(nil, true)),
+		c.cleaner.flush(ctx),
+	)
+}
+
+// Close implements io.Closer, so that `db.Close` shuts the connector down,
+// waiting up to DriverConfig.ShutdownTimeout for queries to be stopped.
+func (c *Connector) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.ShutdownTimeout)
+	defer cancel()
+	return c.Shutdown(ctx)
+}
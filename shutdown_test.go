@@ -0,0 +1,107 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inFlight returns the number of queries tracked by connector.
+func inFlight(connector *Connector) int {
+	connector.queries.mu.Lock()
+	defer connector.queries.mu.Unlock()
+	return len(connector.queries.queries)
+}
+
+func TestConnector_Shutdown(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	hooks := &recordingHooks{}
+	connector := newTestConnector(t, fake, DriverConfig{Hooks: hooks})
+	db := openTestConnector(t, connector)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := db.ExecContext(context.Background(), "select")
+			errs <- err
+		}()
+	}
+	require.Eventually(t, func() bool { return inFlight(connector) == 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, connector.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-errs, ErrShutdown)
+	assert.ErrorIs(t, <-errs, ErrShutdown)
+	assert.ElementsMatch(t, []string{"query-1", "query-2"}, fake.stopped)
+
+	connector.hooks.wait()
+	assert.Contains(t, hooks.events, "cancel query-1 "+ErrShutdown.Error())
+
+	_, err := db.ExecContext(context.Background(), "select")
+	assert.ErrorIs(t, err, ErrShutdown)
+	assert.Len(t, fake.started, 2)
+}
+
+func TestConnector_CloseStopsQueries(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	connector := newTestConnector(t, fake, DriverConfig{})
+	db := openTestConnector(t, connector)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := db.ExecContext(context.Background(), "select")
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return inFlight(connector) == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(t, db.Close())
+	assert.ErrorIs(t, <-errs, ErrShutdown)
+	assert.Equal(t, []string{"query-1"}, fake.stopped)
+}
+
+func TestConn_CloseStopsOwnQueries(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	connector := newTestConnector(t, fake, DriverConfig{})
+
+	first, err := connector.Connect(context.Background())
+	require.NoError(t, err)
+	second, err := connector.Connect(context.Background())
+	require.NoError(t, err)
+
+	// Pretend both connections are waiting on a query.
+	connector.queries.add("query-1", first.(*conn))
+	connector.queries.add("query-2", second.(*conn))
+
+	require.NoError(t, first.Close())
+	assert.Equal(t, []string{"query-1"}, fake.stopped)
+	assert.Equal(t, 1, inFlight(connector))
+}
+
+func TestConnector_ShutdownStopsScanEstimate(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateRunning)
+	hooks := &recordingHooks{}
+	connector := newTestConnector(t, fake, DriverConfig{
+		Hooks:              hooks,
+		CheckScanEstimates: true,
+		MaxBytesScanned:    10000,
+	})
+	db := openTestConnector(t, connector)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := db.ExecContext(context.Background(), "select")
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return inFlight(connector) == 1 }, time.Second, time.Millisecond)
+
+	// The EXPLAIN checking the estimate is stopped, without reporting it.
+	require.NoError(t, connector.Shutdown(context.Background()))
+	assert.ErrorIs(t, <-errs, ErrShutdown)
+	assert.Equal(t, []string{"query-1"}, fake.stopped)
+
+	connector.hooks.wait()
+	assert.Empty(t, hooks.events)
+}
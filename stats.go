@@ -0,0 +1,142 @@
+package athena
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// RuntimeStatistics describe how a query ran in the Athena engine, stage by
+// stage, to help tune slow queries.
+type RuntimeStatistics struct {
+	InputRows   int64
+	InputBytes  int64
+	OutputRows  int64
+	OutputBytes int64
+
+	QueueTime             time.Duration
+	PlanningTime          time.Duration
+	EngineExecutionTime   time.Duration
+	ServiceProcessingTime time.Duration
+	TotalExecutionTime    time.Duration
+
+	// OutputStage is the root of the tree of stages the query ran.
+	OutputStage *Stage
+}
+
+// Stage is a stage of a query. Athena reports timings per stage, not per
+// operator of the stage's plan.
+type Stage struct {
+	StageID int64
+
+	// State is the state of the stage in the engine, e.g. "FINISHED".
+	State         string
+	ExecutionTime time.Duration
+
+	InputRows   int64
+	InputBytes  int64
+	OutputRows  int64
+	OutputBytes int64
+
+	// Plan is the tree of operators the stage ran.
+	Plan      *PlanNode
+	SubStages []*Stage
+}
+
+// PlanNode is an operator of the plan of a stage.
+type PlanNode struct {
+	ID   string
+	Name string
+
+	// RemoteSources are the IDs of the stages the operator reads from.
+	RemoteSources []string
+	Children      []*PlanNode
+}
+
+// RuntimeStatistics returns the runtime statistics of a query execution.
+// They're complete once the query finished.
+func (c *Client) RuntimeStatistics(ctx context.Context, id QueryID) (*RuntimeStatistics, error) {
+	var stats *RuntimeStatistics
+	err := c.withConn(ctx, func(cn *conn) error {
+		var err error
+		stats, err = cn.runtimeStatistics(ctx, string(id))
+		return err
+	})
+	return stats, err
+}
+
+func (c *conn) runtimeStatistics(ctx context.Context, queryID string) (*RuntimeStatistics, error) {
+	resp, err := c.athena.GetQueryRuntimeStatistics(ctx, &athena.GetQueryRuntimeStatisticsInput{
+		QueryExecutionId: aws.String(queryID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newRuntimeStatistics(resp.QueryRuntimeStatistics), nil
+}
+
+func newRuntimeStatistics(in *types.QueryRuntimeStatistics) *RuntimeStatistics {
+	var stats RuntimeStatistics
+	if in == nil {
+		return &stats
+	}
+
+	if rows := in.Rows; rows != nil {
+		stats.InputRows = aws.ToInt64(rows.InputRows)
+		stats.InputBytes = aws.ToInt64(rows.InputBytes)
+		stats.OutputRows = aws.ToInt64(rows.OutputRows)
+		stats.OutputBytes = aws.ToInt64(rows.OutputBytes)
+	}
+
+	if timeline := in.Timeline; timeline != nil {
+		stats.QueueTime = millis(timeline.QueryQueueTimeInMillis)
+		stats.PlanningTime = millis(timeline.QueryPlanningTimeInMillis)
+		stats.EngineExecutionTime = millis(timeline.EngineExecutionTimeInMillis)
+		stats.ServiceProcessingTime = millis(timeline.ServiceProcessingTimeInMillis)
+		stats.TotalExecutionTime = millis(timeline.TotalExecutionTimeInMillis)
+	}
+
+	stats.OutputStage = newStage(in.OutputStage)
+	return &stats
+}
+
+func newStage(in *types.QueryStage) *Stage {
+	if in == nil {
+		return nil
+	}
+
+	stage := &Stage{
+		StageID:       aws.ToInt64(in.StageId),
+		State:         aws.ToString(in.State),
+		ExecutionTime: millis(in.ExecutionTime),
+		InputRows:     aws.ToInt64(in.InputRows),
+		InputBytes:    aws.ToInt64(in.InputBytes),
+		OutputRows:    aws.ToInt64(in.OutputRows),
+		OutputBytes:   aws.ToInt64(in.OutputBytes),
+		Plan:          newPlanNode(in.QueryStagePlan),
+	}
+	for i := range in.SubStages {
+		stage.SubStages = append(stage.SubStages, newStage(&in.SubStages[i]))
+	}
+	return stage
+}
+
+func newPlanNode(in *types.QueryStagePlanNode) *PlanNode {
+	if in == nil {
+		return nil
+	}
+
+	node := &PlanNode{
+		ID:            aws.ToString(in.Identifier),
+		Name:          aws.ToString(in.Name),
+		RemoteSources: in.RemoteSources,
+	}
+	for i := range in.Children {
+		node.Children = append(node.Children, newPlanNode(&in.Children[i]))
+	}
+	return node
+}
@@ -0,0 +1,71 @@
+package athena
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RuntimeStatistics(t *testing.T) {
+	fake := newFakeAthena(types.QueryExecutionStateSucceeded)
+	client := NewClient(openTestDB(t, fake))
+
+	stats, err := client.RuntimeStatistics(context.Background(), "query-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), stats.InputRows)
+	assert.Equal(t, int64(9), stats.OutputRows)
+
+	root := stats.OutputStage
+	require.NotNil(t, root)
+	assert.Equal(t, "RUNNING", root.State)
+	assert.Equal(t, 20*time.Millisecond, root.ExecutionTime)
+	require.NotNil(t, root.Plan)
+	assert.Equal(t, "Output", root.Plan.Name)
+	require.Len(t, root.Plan.Children, 1)
+	assert.Equal(t, "Limit", root.Plan.Children[0].Name)
+
+	require.Len(t, root.SubStages, 1)
+	assert.Equal(t, &Stage{
+		StageID:       1,
+		State:         "FINISHED",
+		ExecutionTime: 10 * time.Millisecond,
+		InputRows:     100,
+		InputBytes:    2048,
+		OutputRows:    9,
+	}, root.SubStages[0])
+}
+
+func TestConn_RuntimeStatisticsThreshold(t *testing.T) {
+	tests := []struct {
+		desc      string
+		threshold time.Duration
+		attached  bool
+	}{
+		{desc: "disabled"},
+		{desc: "faster than threshold", threshold: time.Hour},
+		{desc: "slower than threshold", threshold: time.Second, attached: true},
+	}
+
+	for _, test := range tests {
+		fake := newFakeAthena(types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+		db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+			RuntimeStatisticsThreshold: test.threshold,
+		}))
+
+		var info ExecutionInfo
+		_, err := db.ExecContext(WithExecutionInfo(context.Background(), &info), "select")
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, 2100*time.Millisecond, info.TotalExecutionTime, test.desc)
+
+		if !test.attached {
+			assert.Nil(t, info.RuntimeStatistics, test.desc)
+			continue
+		}
+		require.NotNil(t, info.RuntimeStatistics, test.desc)
+		assert.Equal(t, int64(100), info.RuntimeStatistics.InputRows, test.desc)
+	}
+}
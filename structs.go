@@ -0,0 +1,438 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// errArgsNotSupported is returned by the query helpers when they're given
+// arguments, which the driver would otherwise panic on.
+var errArgsNotSupported = errors.New("athena: query arguments are not supported, format them into the query")
+
+// QueryStructs runs query on db and returns the rows of its results scanned
+// into values of T, which must be a struct type. See StructIterator for how
+// columns map to fields. The driver doesn't support query arguments, so
+// passing any args is an error.
+func QueryStructs[T any](ctx context.Context, db *sql.DB, query string, args ...any) ([]T, error) {
+	it, err := QueryStructIterator[T](ctx, db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var all []T
+	for it.Next() {
+		all = append(all, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// QueryStructIterator runs query on db and returns an iterator over the rows
+// of its results scanned into values of T, which must be a struct type. Like
+// QueryStructs, it returns an error if any args are passed.
+func QueryStructIterator[T any](ctx context.Context, db *sql.DB, query string, args ...any) (*StructIterator[T], error) {
+	if len(args) > 0 {
+		return nil, errArgsNotSupported
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := NewStructIterator[T](rows)
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// StructIterator iterates over rows of results scanned into values of T.
+//
+// Each column is stored in the field of T tagged with its name, e.g.
+// `athena:"user_id"`, or else the field whose name converted to snake case
+// is the column name, e.g. UserID for user_id. Fields of embedded structs are
+// promoted, and fields tagged `athena:"-"` are ignored. A column without a
+// field is an error, while fields without a column are left unset.
+//
+// Values are converted to the types of their fields, e.g. an integer column
+// can be stored in any integer field it fits in. NULL values can only be
+// stored in pointers, slices, maps, interfaces and sql.Scanner
+// implementations such as sql.NullString. Arrays can be stored in slices,
+// and maps and rows in maps and structs, with their elements converted from
+// the text Athena returns for them.
+//
+// Decimals are converted to float64 by the driver before they're stored, so
+// they can only be stored in numeric fields, and lose any precision float64
+// doesn't have.
+type StructIterator[T any] struct {
+	rows    *sql.Rows
+	columns []structColumn
+	values  []any
+	dest    []any
+	cur     T
+	err     error
+}
+
+// structColumn is a column of results, and the field of the struct it's
+// stored in.
+type structColumn struct {
+	name       string
+	athenaType string
+	field      []int
+}
+
+// NewStructIterator returns an iterator over rows, which must be the results
+// of a query run with this driver. The iterator closes rows once closed.
+func NewStructIterator[T any](rows *sql.Rows) (*StructIterator[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("athena: cannot scan rows into %s, which isn't a struct", typ)
+	}
+	fields := structFields(typ)
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	it := &StructIterator[T]{
+		rows:    rows,
+		columns: make([]structColumn, len(types)),
+		values:  make([]any, len(types)),
+		dest:    make([]any, len(types)),
+	}
+	for i, t := range types {
+		field, ok := fields[t.Name()]
+		if !ok {
+			return nil, fmt.Errorf("athena: column %q has no field in %s", t.Name(), typ)
+		}
+		it.columns[i] = structColumn{name: t.Name(), athenaType: t.DatabaseTypeName(), field: field}
+		it.dest[i] = &it.values[i]
+	}
+	return it, nil
+}
+
+// Next moves to the next row, returning false at the end of the results or
+// on error.
+func (it *StructIterator[T]) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	if err := it.rows.Scan(it.dest...); err != nil {
+		it.err = err
+		return false
+	}
+
+	var cur T
+	v := reflect.ValueOf(&cur).Elem()
+	for i, col := range it.columns {
+		if err := setValue(v.FieldByIndex(col.field), col.athenaType, it.values[i]); err != nil {
+			it.err = fmt.Errorf("athena: column %q: %w", col.name, err)
+			return false
+		}
+	}
+	it.cur = cur
+	return true
+}
+
+// Value returns the current row.
+func (it *StructIterator[T]) Value() T {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *StructIterator[T]) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+// Close closes the underlying rows.
+func (it *StructIterator[T]) Close() error {
+	return it.rows.Close()
+}
+
+// structFieldsCache caches the results of structFields.
+var structFieldsCache sync.Map
+
+// structFields returns the index of the field of the struct type typ
+// storing each column.
+func structFields(typ reflect.Type) map[string][]int {
+	if fields, ok := structFieldsCache.Load(typ); ok {
+		return fields.(map[string][]int)
+	}
+
+	fields := map[string][]int{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, tagged := f.Tag.Lookup("athena")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		if f.Anonymous && !tagged && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			for name, index := range structFields(f.Type) {
+				if _, ok := fields[name]; !ok {
+					fields[name] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = snakeCase(f.Name)
+		}
+		// Fields of the struct itself take precedence over promoted ones.
+		fields[name] = []int{i}
+	}
+
+	structFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// snakeCase converts a Go identifier to snake case, e.g. "UserID" to
+// "user_id".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// setValue stores v, a value of the Athena type athenaType as returned by
+// the driver, in dst.
+func setValue(dst reflect.Value, athenaType string, v any) error {
+	switch athenaType {
+	case "array", "map", "row":
+		if s, ok := v.(string); ok {
+			return setText(dst, &s)
+		}
+	}
+
+	if v == nil {
+		return setNull(dst)
+	}
+	if scanner, ok := asScanner(dst); ok {
+		return scanner.Scan(v)
+	}
+	if dst.Kind() == reflect.Pointer {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		return setValue(dst.Elem(), athenaType, v)
+	}
+
+	switch v := v.(type) {
+	case int64:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !dst.OverflowInt(v) {
+				dst.SetInt(v)
+				return nil
+			}
+			return fmt.Errorf("%d overflows %s", v, dst.Type())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v >= 0 && !dst.OverflowUint(uint64(v)) {
+				dst.SetUint(uint64(v))
+				return nil
+			}
+			return fmt.Errorf("%d overflows %s", v, dst.Type())
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(float64(v))
+			return nil
+		}
+	case float64:
+		switch dst.Kind() {
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(v)
+			return nil
+		}
+	case bool:
+		if dst.Kind() == reflect.Bool {
+			dst.SetBool(v)
+			return nil
+		}
+	case string:
+		if dst.Kind() == reflect.String {
+			dst.SetString(v)
+			return nil
+		}
+	case time.Time:
+		if dst.Type() == timeType {
+			dst.Set(reflect.ValueOf(v))
+			return nil
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if dst.Kind() == reflect.Interface && rv.Type().AssignableTo(dst.Type()) {
+		dst.Set(rv)
+		return nil
+	}
+	return fmt.Errorf("cannot store %s value of type %T in %s", athenaType, v, dst.Type())
+}
+
+// setText stores a value from the text Athena returns for it in dst. It's
+// used for the elements of complex types, whose types Athena doesn't report.
+func setText(dst reflect.Value, text *string) error {
+	if text == nil {
+		return setNull(dst)
+	}
+	if scanner, ok := asScanner(dst); ok {
+		return scanner.Scan(*text)
+	}
+
+	s := *text
+	switch dst.Kind() {
+	case reflect.Pointer:
+		dst.Set(reflect.New(dst.Type().Elem()))
+		return setText(dst.Elem(), text)
+	case reflect.Interface:
+		if reflect.TypeOf(s).AssignableTo(dst.Type()) {
+			dst.Set(reflect.ValueOf(s))
+			return nil
+		}
+	case reflect.String:
+		dst.SetString(s)
+		return nil
+	case reflect.Bool:
+		t, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(t)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		elems, err := parseArray(s)
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := setText(slice.Index(i), elem); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case reflect.Map:
+		entries, err := parseMap(s)
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(dst.Type(), len(entries))
+		for _, entry := range entries {
+			key := reflect.New(dst.Type().Key()).Elem()
+			if err := setText(key, &entry.key); err != nil {
+				return fmt.Errorf("key %q: %w", entry.key, err)
+			}
+			value := reflect.New(dst.Type().Elem()).Elem()
+			if err := setText(value, entry.value); err != nil {
+				return fmt.Errorf("key %q: %w", entry.key, err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		dst.Set(m)
+		return nil
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			for _, layout := range []string{TimestampLayout, TimestampWithTimeZoneLayout, DateLayout} {
+				if t, err := time.Parse(layout, s); err == nil {
+					dst.Set(reflect.ValueOf(t))
+					return nil
+				}
+			}
+			return fmt.Errorf("cannot parse '%s' as time", s)
+		}
+
+		entries, err := parseMap(s)
+		if err != nil {
+			return err
+		}
+		fields := structFields(dst.Type())
+		for _, entry := range entries {
+			field, ok := fields[entry.key]
+			if !ok {
+				return fmt.Errorf("row field %q has no field in %s", entry.key, dst.Type())
+			}
+			if err := setText(dst.FieldByIndex(field), entry.value); err != nil {
+				return fmt.Errorf("field %q: %w", entry.key, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot store '%s' in %s", s, dst.Type())
+}
+
+// setNull stores NULL in dst.
+func setNull(dst reflect.Value) error {
+	if scanner, ok := asScanner(dst); ok {
+		return scanner.Scan(nil)
+	}
+	switch dst.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot store NULL in %s", dst.Type())
+}
+
+func asScanner(dst reflect.Value) (sql.Scanner, bool) {
+	if dst.Kind() != reflect.Pointer && dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		return dst.Addr().Interface().(sql.Scanner), true
+	}
+	return nil, false
+}
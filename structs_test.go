@@ -0,0 +1,149 @@
+package athena
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedRow struct {
+	ID     int32
+	Name   *string
+	Score  sql.NullFloat64
+	Active *bool
+	Day    *time.Time `athena:"day"`
+	At     *time.Time
+	Ignore string `athena:"-"`
+}
+
+func TestQueryStructs(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	all, err := QueryStructs[typedRow](context.Background(), db, "typed")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	assert.EqualValues(t, 1, all[0].ID)
+	assert.Equal(t, `a "quoted", name`, *all[0].Name)
+	assert.Equal(t, sql.NullFloat64{Float64: 1.5, Valid: true}, all[0].Score)
+	assert.True(t, *all[0].Active)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *all[0].Day)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 30, 0, 250e6, time.UTC), *all[0].At)
+
+	assert.Equal(t, typedRow{ID: 2}, all[1])
+}
+
+func TestQueryStructs_Complex(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	type point struct {
+		X int
+		Y float64
+	}
+	type embedded struct {
+		Price *float64
+	}
+	type complexRow struct {
+		embedded
+		Tags  []*string
+		Attrs map[string]*string
+		Point *point
+	}
+
+	all, err := QueryStructs[complexRow](context.Background(), db, "select complex")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	assert.Equal(t, 12.34, *all[0].Price)
+	require.Len(t, all[0].Tags, 2)
+	assert.Equal(t, "a", *all[0].Tags[0])
+	assert.Nil(t, all[0].Tags[1])
+	assert.Equal(t, map[string]*string{"k": ptr("v"), "n": nil}, all[0].Attrs)
+	assert.Equal(t, &point{X: 1, Y: 2}, all[0].Point)
+
+	assert.Equal(t, []*string{}, all[1].Tags)
+	assert.Nil(t, all[1].Attrs)
+	assert.Nil(t, all[1].Point)
+}
+
+func TestQueryStructs_Errors(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+	ctx := context.Background()
+
+	_, err := QueryStructs[struct{ ID int64 }](ctx, db, "typed")
+	assert.EqualError(t, err, `athena: column "name" has no field in struct { ID int64 }`)
+
+	type notNullable struct {
+		typedRow
+		Name string
+	}
+	_, err = QueryStructs[notNullable](ctx, db, "typed")
+	assert.EqualError(t, err, `athena: column "name": cannot store NULL in string`)
+
+	type mismatched struct {
+		typedRow
+		Active string
+	}
+	_, err = QueryStructs[mismatched](ctx, db, "typed")
+	assert.EqualError(t, err, `athena: column "active": cannot store boolean value of type bool in string`)
+
+	// Integers can be stored in any integer field they fit in.
+	type narrower struct {
+		typedRow
+		ID uint8
+	}
+	all, err := QueryStructs[narrower](ctx, db, "typed")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, all[1].ID)
+
+	_, err = QueryStructs[int](ctx, db, "typed")
+	assert.EqualError(t, err, "athena: cannot scan rows into int, which isn't a struct")
+
+	// Arguments are an error rather than a panic of the driver.
+	_, err = QueryStructs[typedRow](ctx, db, "typed", 1)
+	assert.Equal(t, errArgsNotSupported, err)
+	_, err = QueryStructIterator[typedRow](ctx, db, "typed", 1)
+	assert.Equal(t, errArgsNotSupported, err)
+}
+
+func TestStructIterator(t *testing.T) {
+	db := openTestConnector(t, newTestConnector(t, newFakeAthena(), DriverConfig{}))
+
+	it, err := QueryStructIterator[struct {
+		FirstName string
+		LastName  string
+	}](context.Background(), db, "select")
+	require.NoError(t, err)
+	defer it.Close()
+
+	n := 0
+	for it.Next() {
+		assert.NotEmpty(t, it.Value().FirstName)
+		assert.NotEmpty(t, it.Value().LastName)
+		n++
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, 9, n)
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"ID":         "id",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"FirstName":  "first_name",
+		"Field1":     "field1",
+		"Utf8Name":   "utf8_name",
+		"lowercase":  "lowercase",
+	} {
+		assert.Equal(t, expected, snakeCase(name), name)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
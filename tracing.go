@@ -0,0 +1,91 @@
+package athena
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/segmentio/go-athena"
+
+// Attributes recorded on the spans of the driver.
+const (
+	attrDBSystem      = attribute.Key("db.system")
+	attrQueryID       = attribute.Key("athena.query_id")
+	attrDatabase      = attribute.Key("athena.database")
+	attrWorkGroup     = attribute.Key("athena.workgroup")
+	attrStatementType = attribute.Key("athena.statement_type")
+	attrState         = attribute.Key("athena.state")
+	attrStartAttempts = attribute.Key("athena.start_attempts")
+	attrDataScanned   = attribute.Key("athena.data_scanned_bytes")
+	attrQueueTime     = attribute.Key("athena.queue_time_ms")
+	attrPlanningTime  = attribute.Key("athena.planning_time_ms")
+	attrEngineTime    = attribute.Key("athena.engine_execution_time_ms")
+	attrTotalTime     = attribute.Key("athena.total_execution_time_ms")
+	attrResultReused  = attribute.Key("athena.result_reused")
+	attrPage          = attribute.Key("athena.page")
+	attrPageRows      = attribute.Key("athena.page_rows")
+	attrPriority      = attribute.Key("athena.priority")
+	attrAdmissionWait = attribute.Key("athena.admission_wait_ms")
+	attrCached        = attribute.Key("athena.cached")
+)
+
+// stateChangeEvent is the span event recorded when a query changes state.
+const stateChangeEvent = "athena.state_change"
+
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// endSpan ends span, recording err if set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// recordStateChange adds an event to the span in ctx for a query moving to a
+// new state.
+func recordStateChange(ctx context.Context, qe *types.QueryExecution) {
+	trace.SpanFromContext(ctx).AddEvent(stateChangeEvent, trace.WithAttributes(
+		attrState.String(string(qe.Status.State)),
+	))
+}
+
+// executionAttributes returns the attributes describing a query execution.
+func executionAttributes(qe *types.QueryExecution) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attrQueryID.String(aws.ToString(qe.QueryExecutionId)),
+	}
+	if qe.StatementType != "" {
+		attrs = append(attrs, attrStatementType.String(string(qe.StatementType)))
+	}
+	if qe.Status != nil {
+		attrs = append(attrs, attrState.String(string(qe.Status.State)))
+	}
+
+	if stats := qe.Statistics; stats != nil {
+		attrs = append(attrs,
+			attrDataScanned.Int64(aws.ToInt64(stats.DataScannedInBytes)),
+			attrQueueTime.Int64(aws.ToInt64(stats.QueryQueueTimeInMillis)),
+			attrPlanningTime.Int64(aws.ToInt64(stats.QueryPlanningTimeInMillis)),
+			attrEngineTime.Int64(aws.ToInt64(stats.EngineExecutionTimeInMillis)),
+			attrTotalTime.Int64(aws.ToInt64(stats.TotalExecutionTimeInMillis)),
+		)
+		if stats.ResultReuseInformation != nil {
+			attrs = append(attrs, attrResultReused.Bool(stats.ResultReuseInformation.ReusedPreviousResult))
+		}
+	}
+
+	return attrs
+}
@@ -0,0 +1,70 @@
+package athena
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	fake := newFakeAthena(types.QueryExecutionStateQueued, types.QueryExecutionStateRunning, types.QueryExecutionStateSucceeded)
+	db := openTestConnector(t, newTestConnector(t, fake, DriverConfig{
+		WorkGroup:      "analytics",
+		TracerProvider: tp,
+	}))
+
+	rows, err := db.QueryContext(context.Background(), "select")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	spans := exporter.GetSpans()
+	byName := map[string][]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+	require.Len(t, byName["athena.query"], 1)
+	require.Len(t, byName["athena.StartQueryExecution"], 1)
+	require.Len(t, byName["athena.wait"], 1)
+	require.Len(t, byName["athena.GetQueryResults"], 2)
+
+	query := byName["athena.query"][0]
+	attrs := attribute.NewSet(query.Attributes...)
+	value, _ := attrs.Value(attrQueryID)
+	assert.Equal(t, "query-1", value.AsString())
+	value, _ = attrs.Value(attrWorkGroup)
+	assert.Equal(t, "analytics", value.AsString())
+	value, _ = attrs.Value(attrDataScanned)
+	assert.Equal(t, int64(3072), value.AsInt64())
+
+	for _, name := range []string{"athena.StartQueryExecution", "athena.wait", "athena.GetQueryResults"} {
+		for _, span := range byName[name] {
+			assert.Equal(t, query.SpanContext.SpanID(), span.Parent.SpanID(), name)
+		}
+	}
+
+	var states []string
+	for _, event := range byName["athena.wait"][0].Events {
+		eventAttrs := attribute.NewSet(event.Attributes...)
+		value, _ := eventAttrs.Value(attrState)
+		states = append(states, value.AsString())
+	}
+	assert.Equal(t, []string{"QUEUED", "RUNNING", "SUCCEEDED"}, states)
+
+	pages := attribute.NewSet(byName["athena.GetQueryResults"][1].Attributes...)
+	value, _ = pages.Value(attrPage)
+	assert.Equal(t, int64(2), value.AsInt64())
+	value, _ = pages.Value(attrPageRows)
+	assert.Equal(t, int64(5), value.AsInt64())
+}
@@ -62,6 +62,11 @@ func convertValue(athenaType string, rawValue *string) (interface{}, error) {
 		return time.Parse(TimestampWithTimeZoneLayout, val)
 	case "date":
 		return time.Parse(DateLayout, val)
+	case "array", "map", "row":
+		// Athena doesn't report the types of their elements, so complex
+		// values are returned as their text, e.g. "[1, 2]" or "{a=1}", which
+		// can be scanned into strings. QueryStructs parses them.
+		return val, nil
 	default:
 		panic(fmt.Errorf("unknown type `%s` with value %s", athenaType, val))
 	}